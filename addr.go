@@ -4,6 +4,10 @@
 
 package i2cm
 
+import (
+	"fmt"
+)
+
 // Addr represents an I2C device address. It supports both 7 bit and
 // 10 bit addressing. Other address width are possible but will not
 // be supported by transaction drivers.
@@ -16,10 +20,34 @@ type Addr interface {
 	GetAddrLen() int
 }
 
+// AddrFramer is implemented by Addrs which are put on the bus in a way
+// other than the standard 7 or 10 bit addressing, e.g. with a
+// sub-address byte following the device address. Transactions send
+// WriteFrame after the start condition in place of the device address
+// for writing, and ReadFrame after the repeated start of the read phase
+// in place of the device address for reading. A NACK of any of the
+// bytes is treated like a NACK of the device address. Addr7 and Addr10
+// do not implement AddrFramer.
+type AddrFramer interface {
+	WriteFrame() []byte
+	ReadFrame() []byte
+}
+
 // Addr7 represents a 7 bit I2C address. The device address must be
-// right aligned.
+// right aligned. Values beyond 7 bits are masked by GetBaseAddr, use
+// NewAddr7 to have them rejected instead.
 type Addr7 uint8
 
+// NewAddr7 returns v as an Addr7. It returns an error if v does not fit
+// into 7 bits, e.g. because an 8 bit address including the R/W bit was
+// passed.
+func NewAddr7(v uint8) (Addr7, error) {
+	if v > 0x7f {
+		return 0, fmt.Errorf("NewAddr7: address %#02x exceeds 7 bits", v)
+	}
+	return Addr7(v), nil
+}
+
 func (a Addr7) GetBaseAddr() uint16 {
 	return uint16(a & 0x7f)
 }
@@ -28,10 +56,27 @@ func (a Addr7) GetAddrLen() int {
 	return 7
 }
 
-// Addr7 represents a 10 bit I2C address. The device address must be
-// right aligned.
+// String returns the address in the form Addr7(0x50). The address is
+// printed right aligned, as passed to Addr7, not shifted by the R/W
+// bit.
+func (a Addr7) String() string {
+	return fmt.Sprintf("Addr7(0x%02x)", uint8(a))
+}
+
+// Addr10 represents a 10 bit I2C address. The device address must be
+// right aligned. Values beyond 10 bits are masked by GetBaseAddr, use
+// NewAddr10 to have them rejected instead.
 type Addr10 uint16
 
+// NewAddr10 returns v as an Addr10. It returns an error if v does not
+// fit into 10 bits.
+func NewAddr10(v uint16) (Addr10, error) {
+	if v > 0x03ff {
+		return 0, fmt.Errorf("NewAddr10: address %#03x exceeds 10 bits", v)
+	}
+	return Addr10(v), nil
+}
+
 func (a Addr10) GetBaseAddr() uint16 {
 	return uint16(a & 0x03ff)
 }
@@ -39,3 +84,9 @@ func (a Addr10) GetBaseAddr() uint16 {
 func (a Addr10) GetAddrLen() int {
 	return 10
 }
+
+// String returns the address in the form Addr10(0x3a0). Like for
+// Addr7, the address is printed right aligned.
+func (a Addr10) String() string {
+	return fmt.Sprintf("Addr10(0x%03x)", uint16(a))
+}
@@ -0,0 +1,42 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNewAddr(t *testing.T) {
+	if a, err := NewAddr7(0x7f); err != nil || a != 0x7f {
+		t.Fatalf("NewAddr7(0x7f): expected 0x7f, got %v, %v", a, err)
+	}
+	if _, err := NewAddr7(0xa0); err == nil {
+		t.Fatal("NewAddr7(0xa0): expected an error")
+	}
+
+	if a, err := NewAddr10(0x3ff); err != nil || a != 0x3ff {
+		t.Fatalf("NewAddr10(0x3ff): expected 0x3ff, got %v, %v", a, err)
+	}
+	if _, err := NewAddr10(0x400); err == nil {
+		t.Fatal("NewAddr10(0x400): expected an error")
+	}
+}
+
+func TestAddrString(t *testing.T) {
+	for _, c := range []struct {
+		a   Addr
+		exp string
+	}{
+		{Addr7(0x50), "Addr7(0x50)"},
+		{Addr7(0x05), "Addr7(0x05)"},
+		{Addr10(0x3a0), "Addr10(0x3a0)"},
+		{Addr10(0x050), "Addr10(0x050)"},
+	} {
+		if s := fmt.Sprint(c.a); s != c.exp {
+			t.Errorf("expected %s, got %s", c.exp, s)
+		}
+	}
+}
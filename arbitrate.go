@@ -0,0 +1,62 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+// Arbiter holds the hooks Arbitrate calls around transactions, e.g. to
+// acquire and release the bus in multi-master setups by a request and
+// grant line or a lock shared with the other masters. Both hooks may be
+// nil.
+type Arbiter struct {
+	// BeforeStart is called before each transaction. If it returns an
+	// error, e.g. because the bus is busy, the transaction is not
+	// carried out and the error is returned.
+	BeforeStart func() error
+
+	// AfterStop is called after each transaction for which BeforeStart
+	// succeeded, whether the transaction failed or not.
+	AfterStop func()
+}
+
+type arbitrated struct {
+	t Transactor
+	a Arbiter
+}
+
+// Arbitrate returns a Transactor which carries out transactions on t
+// surrounded by the hooks of a.
+func Arbitrate(t Transactor, a Arbiter) Transactor {
+	return &arbitrated{t, a}
+}
+
+// acquire calls the BeforeStart hook.
+func (a *arbitrated) acquire() error {
+	if a.a.BeforeStart != nil {
+		return a.a.BeforeStart()
+	}
+	return nil
+}
+
+// release calls the AfterStop hook.
+func (a *arbitrated) release() {
+	if a.a.AfterStop != nil {
+		a.a.AfterStop()
+	}
+}
+
+func (a *arbitrated) Transact8x8(addr Addr, regaddr uint8, w []byte, rb []byte) (int, int, error) {
+	if err := a.acquire(); err != nil {
+		return 0, 0, err
+	}
+	defer a.release()
+	return a.t.Transact8x8(addr, regaddr, w, rb)
+}
+
+func (a *arbitrated) Transact16x8(addr Addr, regaddr uint16, w []byte, rb []byte) (int, int, error) {
+	if err := a.acquire(); err != nil {
+		return 0, 0, err
+	}
+	defer a.release()
+	return a.t.Transact16x8(addr, regaddr, w, rb)
+}
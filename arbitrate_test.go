@@ -0,0 +1,63 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestArbitrate(t *testing.T) {
+	errBusy := errors.New("bus busy")
+
+	var log []string
+	busy := false
+	a := Arbiter{
+		BeforeStart: func() error {
+			log = append(log, "acquire")
+			if busy {
+				return errBusy
+			}
+			return nil
+		},
+		AfterStop: func() { log = append(log, "release") },
+	}
+
+	s := &startRecorder{}
+	tr := Arbitrate(s, a)
+	if _, _, err := tr.Transact8x8(Addr7(0x50), 0, nil, nil); err != nil {
+		t.Fatalf("transaction failed: %v", err)
+	}
+	if _, _, err := tr.Transact16x8(Addr7(0x50), 0, nil, nil); err != nil {
+		t.Fatalf("transaction failed: %v", err)
+	}
+
+	// a busy bus is not touched and not released
+	busy = true
+	if _, _, err := tr.Transact8x8(Addr7(0x50), 0, nil, nil); err != errBusy {
+		t.Fatalf("expected errBusy, got %v", err)
+	}
+	if len(s.starts) != 2 {
+		t.Fatalf("expected 2 transactions on the bus, got %d", len(s.starts))
+	}
+
+	// failed transactions release the bus
+	busy = false
+	tr = Arbitrate(NewTransactor(&alwaysNACK{}), a)
+	if _, _, err := tr.Transact8x8(Addr7(0x50), 0, nil, nil); err != NoSuchDevice {
+		t.Fatalf("expected NoSuchDevice, got %v", err)
+	}
+
+	exp := "[acquire release acquire release acquire acquire release]"
+	if fmt.Sprint(log) != exp {
+		t.Fatalf("expected %s, got %v", exp, log)
+	}
+
+	// hooks are optional
+	if _, _, err := Arbitrate(s, Arbiter{}).Transact8x8(Addr7(0x50), 0, nil, nil); err != nil {
+		t.Fatalf("transaction without hooks failed: %v", err)
+	}
+}
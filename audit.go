@@ -0,0 +1,145 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"fmt"
+	"time"
+)
+
+// AuditOp identifies the EEPROM24 method an AuditEvent was emitted for.
+type AuditOp int
+
+const (
+	AuditRead AuditOp = iota
+	AuditWrite
+	AuditSeek
+	AuditClose
+)
+
+var auditOpNames = []string{
+	"read",
+	"write",
+	"seek",
+	"close",
+}
+
+func (o AuditOp) String() string {
+	if o < 0 || int(o) >= len(auditOpNames) {
+		return fmt.Sprintf("AuditOp(%d)", int(o))
+	}
+	return auditOpNames[o]
+}
+
+// AuditEvent describes an access to an EEPROM24 wrapped by
+// NewAuditedEEPROM24.
+type AuditEvent struct {
+	Op   AuditOp
+	Off  int64     // absolute position accessed by Read and Write, new position for Seek, -1 for Close
+	Len  int       // number of bytes transferred
+	Err  error     // error returned by the method
+	Time time.Time // time the method was called
+}
+
+type auditedEEPROM24 struct {
+	e    EEPROM24
+	sink func(AuditEvent)
+}
+
+// sizedAuditedEEPROM24 is an auditedEEPROM24 of a Sizer.
+type sizedAuditedEEPROM24 struct {
+	*auditedEEPROM24
+}
+
+// NewAuditedEEPROM24 returns an EEPROM24 which passes all calls on to e
+// and emits an AuditEvent to sink for each Read, Write, Seek and Close.
+// ReadAt and WriteAt are reported as reads and writes. The events carry
+// absolute positions, which are learned from e's Tell. If e supports
+// Locked, the position is learned under e's lock together with the
+// access, so that the events are correct with concurrent users of e.
+// sink is called synchronously after each call to e and should return
+// quickly. The returned EEPROM24 implements Syncer, and Sizer if e
+// does.
+func NewAuditedEEPROM24(e EEPROM24, sink func(AuditEvent)) EEPROM24 {
+	a := &auditedEEPROM24{e, sink}
+	if _, ok := e.(Sizer); ok {
+		return sizedAuditedEEPROM24{a}
+	}
+	return a
+}
+
+// transfer carries out a Read or a Write with fn, recording the
+// position it started at.
+func (a *auditedEEPROM24) transfer(op AuditOp, fn func(EEPROM24) (int, error)) (int, error) {
+	ev := AuditEvent{Op: op, Time: time.Now()}
+	lockedDo(a.e, func(l EEPROM24) error {
+		ev.Off = l.Tell()
+		ev.Len, ev.Err = fn(l)
+		return nil
+	})
+	a.sink(ev)
+	return ev.Len, ev.Err
+}
+
+func (a *auditedEEPROM24) Read(b []byte) (int, error) {
+	return a.transfer(AuditRead, func(l EEPROM24) (int, error) { return l.Read(b) })
+}
+
+func (a *auditedEEPROM24) Write(b []byte) (int, error) {
+	return a.transfer(AuditWrite, func(l EEPROM24) (int, error) { return l.Write(b) })
+}
+
+func (a *auditedEEPROM24) ReadAt(b []byte, off int64) (int, error) {
+	ev := AuditEvent{Op: AuditRead, Off: off, Time: time.Now()}
+	n, err := a.e.ReadAt(b, off)
+	ev.Len, ev.Err = n, err
+	a.sink(ev)
+	return n, err
+}
+
+func (a *auditedEEPROM24) WriteAt(b []byte, off int64) (int, error) {
+	ev := AuditEvent{Op: AuditWrite, Off: off, Time: time.Now()}
+	n, err := a.e.WriteAt(b, off)
+	ev.Len, ev.Err = n, err
+	a.sink(ev)
+	return n, err
+}
+
+func (a *auditedEEPROM24) Seek(offset int64, whence int) (int64, error) {
+	ev := AuditEvent{Op: AuditSeek, Time: time.Now()}
+	var p int64
+	lockedDo(a.e, func(l EEPROM24) error {
+		p, ev.Err = l.Seek(offset, whence)
+		ev.Off = l.Tell()
+		return nil
+	})
+	a.sink(ev)
+	return p, ev.Err
+}
+
+func (a *auditedEEPROM24) Tell() int64 {
+	return a.e.Tell()
+}
+
+func (a *auditedEEPROM24) Close() error {
+	ev := AuditEvent{Op: AuditClose, Off: -1, Time: time.Now()}
+	err := a.e.Close()
+	ev.Err = err
+	a.sink(ev)
+	return err
+}
+
+// Sync implements Syncer. EEPROM24s which are no Syncer do not buffer
+// written data, so there is nothing to do for them.
+func (a *auditedEEPROM24) Sync() error {
+	if s, ok := a.e.(Syncer); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+func (a sizedAuditedEEPROM24) Size() int64 {
+	return a.e.(Sizer).Size()
+}
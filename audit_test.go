@@ -0,0 +1,111 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"io"
+	"sync"
+	"testing"
+)
+
+func TestAuditedEEPROM24(t *testing.T) {
+	fake, mem := NewFakeEEPROM24(Conf_24C02)
+	copy(mem[0xf0:], []byte{1, 2, 3, 4})
+
+	var events []AuditEvent
+	ee := NewAuditedEEPROM24(fake, func(ev AuditEvent) { events = append(events, ev) })
+
+	ee.Seek(0x10, 0)
+	ee.Write([]byte{0xaa, 0xbb})
+	ee.Seek(-0x10, 2)
+	b := make([]byte, 4)
+	if n, err := ee.Read(b); n != 4 || err != nil || string(b) != "\x01\x02\x03\x04" {
+		t.Fatalf("expected to read 01 02 03 04, got % x, %d, %v", b, n, err)
+	}
+	ee.Seek(0, 2)
+	ee.Read(b)
+	ee.Close()
+
+	exp := []AuditEvent{
+		{Op: AuditSeek, Off: 0x10},
+		{Op: AuditWrite, Off: 0x10, Len: 2},
+		{Op: AuditSeek, Off: 0xf0},
+		{Op: AuditRead, Off: 0xf0, Len: 4},
+		{Op: AuditSeek, Off: 0x100},
+		{Op: AuditRead, Off: 0x100, Err: io.EOF},
+		{Op: AuditClose, Off: -1},
+	}
+	if len(events) != len(exp) {
+		t.Fatalf("expected %d events, got %d: %v", len(exp), len(events), events)
+	}
+	for i, ev := range events {
+		if ev.Time.IsZero() {
+			t.Errorf("event %d has no time", i)
+		}
+		ev.Time = exp[i].Time
+		if ev != exp[i] {
+			t.Errorf("event %d: expected %+v, got %+v", i, exp[i], ev)
+		}
+	}
+
+	if string(mem[0x10:0x12]) != "\xaa\xbb" {
+		t.Fatalf("write did not reach the device, memory holds % x", mem[0x10:0x12])
+	}
+}
+
+func TestAuditedEEPROM24Concurrent(t *testing.T) {
+	fake, _ := NewFakeEEPROM24(Conf_24C02)
+
+	var mu sync.Mutex
+	seen := make(map[int64]bool)
+	ee := NewAuditedEEPROM24(fake, func(ev AuditEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		if seen[ev.Off] {
+			t.Errorf("position %#x reported twice", ev.Off)
+		}
+		seen[ev.Off] = true
+	})
+
+	// each read advances the file pointer by one byte, so the reads
+	// cover the positions 0 to 63 once each
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b := make([]byte, 1)
+			for i := 0; i < 16; i++ {
+				ee.Read(b)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(seen) != 64 {
+		t.Fatalf("expected 64 positions, got %d", len(seen))
+	}
+}
+
+func TestAuditedEEPROM24OptionalInterfaces(t *testing.T) {
+	fake, _ := NewFakeEEPROM24(Conf_24C02)
+	sink := func(AuditEvent) {}
+
+	ee := NewAuditedEEPROM24(fake, sink)
+	if s, ok := ee.(Sizer); !ok || s.Size() != int64(Conf_24C02.Size) {
+		t.Fatalf("expected a Sizer of %d bytes", Conf_24C02.Size)
+	}
+	if s, ok := ee.(Syncer); !ok || s.Sync() != nil {
+		t.Fatalf("expected a Syncer")
+	}
+
+	ee = NewAuditedEEPROM24(struct{ EEPROM24 }{fake}, sink)
+	if _, ok := ee.(Sizer); ok {
+		t.Fatalf("the wrapper of an EEPROM24 without Size is a Sizer")
+	}
+	if s, ok := ee.(Syncer); !ok || s.Sync() != nil {
+		t.Fatalf("expected a Syncer")
+	}
+}
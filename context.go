@@ -0,0 +1,110 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"context"
+	"fmt"
+)
+
+// TransactorContext is like Transactor8x8, but the transaction can be
+// cancelled by ctx. The context is checked before each byte transferred
+// on the bus, a cancelled transaction is aborted by a stop condition.
+// The error returned for a cancelled transaction wraps ctx.Err(), i.e.
+// context.Canceled or context.DeadlineExceeded.
+type TransactorContext interface {
+	Transact8x8Ctx(ctx context.Context, addr Addr, regaddr uint8, w, r []byte) (int, int, error)
+}
+
+type correlationIDKey struct{}
+
+// CorrelationIDKey is the context key under which a correlation ID,
+// e.g. the ID of the trace or span a bus operation belongs to, is
+// stored as a string. Transactions carried out by Transact8x8Ctx on a
+// RecordingMaster record the ID with their TraceEntries. Set it with
+// WithCorrelationID.
+var CorrelationIDKey = correlationIDKey{}
+
+// WithCorrelationID returns a copy of ctx carrying id under
+// CorrelationIDKey.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, CorrelationIDKey, id)
+}
+
+// CorrelationID returns the correlation ID carried by ctx, if any.
+func CorrelationID(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(CorrelationIDKey).(string)
+	return id, ok
+}
+
+// correlator is implemented by masters recording correlation IDs.
+// correlate sets the ID of the following operations and returns a
+// function restoring the previous one.
+type correlator interface {
+	correlate(id string) func()
+}
+
+type transactor8x8Ctx struct {
+	m I2CMaster
+}
+
+// NewTransact8x8Ctx returns a TransactorContext carrying out
+// transactions on m. If m is a RecordingMaster, the correlation ID of
+// the context, c.f. WithCorrelationID, is recorded with the operations
+// of the transaction.
+func NewTransact8x8Ctx(m I2CMaster) TransactorContext {
+	return transactor8x8Ctx{m}
+}
+
+func (t transactor8x8Ctx) Transact8x8Ctx(ctx context.Context, addr Addr, regaddr uint8, w, r []byte) (int, int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, 0, fmt.Errorf("I2C transaction: %w", err)
+	}
+	if c, ok := t.m.(correlator); ok {
+		if id, ok := CorrelationID(ctx); ok {
+			defer c.correlate(id)()
+		}
+	}
+	res := transact8x8(ctxMaster{t.m, ctx}, &Transact8x8Options{}, addr, regaddr, w, r)
+	return res.BytesWritten, res.BytesRead, res.Err
+}
+
+// ctxMaster fails all operations on m but stop conditions once ctx is
+// done.
+type ctxMaster struct {
+	m   I2CMaster
+	ctx context.Context
+}
+
+func (m ctxMaster) Start() error {
+	if err := m.ctx.Err(); err != nil {
+		return fmt.Errorf("I2C transaction: %w", err)
+	}
+	return m.m.Start()
+}
+
+func (m ctxMaster) Stop() error {
+	return m.m.Stop()
+}
+
+func (m ctxMaster) ReadByte(ack bool) (byte, error) {
+	if err := m.ctx.Err(); err != nil {
+		return 0, fmt.Errorf("I2C transaction: %w", err)
+	}
+	return m.m.ReadByte(ack)
+}
+
+func (m ctxMaster) WriteByte(b byte) error {
+	if err := m.ctx.Err(); err != nil {
+		return fmt.Errorf("I2C transaction: %w", err)
+	}
+	return m.m.WriteByte(b)
+}
+
+// SupportsRepeatedStart implements NoRepeatedStart on behalf of the
+// underlying master.
+func (m ctxMaster) SupportsRepeatedStart() bool {
+	return supportsRepeatedStart(m.m)
+}
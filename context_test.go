@@ -0,0 +1,97 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// cancelAfter cancels a context after n bytes were written.
+type cancelAfter struct {
+	I2CMaster
+	n      int
+	cancel context.CancelFunc
+}
+
+func (c *cancelAfter) WriteByte(b byte) error {
+	if c.n--; c.n == 0 {
+		c.cancel()
+	}
+	return c.I2CMaster.WriteByte(b)
+}
+
+func TestTransact8x8Ctx(t *testing.T) {
+	md := newmemdev256(Addr7(0x50))
+	md.mem[0x10] = 0x42
+	tr := NewTransact8x8Ctx(md)
+
+	r := make([]byte, 1)
+	if nw, nr, err := tr.Transact8x8Ctx(context.Background(), Addr7(0x50), 0x10, nil, r); nw != 0 || nr != 1 || err != nil || r[0] != 0x42 {
+		t.Fatalf("expected to read 0x42, got %d, %d, %v, % x", nw, nr, err, r)
+	}
+
+	// cancelled after the register address: the data is not written and
+	// the transaction is stopped
+	ctx, cancel := context.WithCancel(context.Background())
+	m := NewRecordingMaster(&cancelAfter{md, 2, cancel})
+	nw, _, err := NewTransact8x8Ctx(m).Transact8x8Ctx(ctx, Addr7(0x50), 0x20, []byte{1, 2}, nil)
+	if nw != 0 || !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected (0, context.Canceled), got (%d, %v)", nw, err)
+	}
+	checkLog(t, recorded(m), []i2cItem{{t_START, 0, false, nil},
+		{t_WRITE, 0xa0, false, nil},
+		{t_WRITE, 0x20, false, nil},
+		{t_STOP, 0, false, nil}})
+	if md.mem[0x20] != 0 {
+		t.Fatalf("cancelled transaction wrote data")
+	}
+
+	// an expired context does not touch the bus
+	ctx, cancel = context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	m = NewRecordingMaster(md)
+	if _, _, err := NewTransact8x8Ctx(m).Transact8x8Ctx(ctx, Addr7(0x50), 0x10, nil, r); !errors.Is(err, context.DeadlineExceeded) || errors.Is(err, NACKReceived) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if log := recorded(m); len(log) != 0 {
+		t.Fatalf("expired context accessed the bus: %v", log)
+	}
+}
+
+func TestTransact8x8CtxCorrelationID(t *testing.T) {
+	r := NewRecordingMaster(&scriptedMaster{rd: []byte{0x42, 0x43}})
+	tr := NewTransact8x8Ctx(r)
+
+	ctx := WithCorrelationID(context.Background(), "span-7")
+	if id, ok := CorrelationID(ctx); id != "span-7" || !ok {
+		t.Fatalf("expected correlation ID span-7, got %q, %v", id, ok)
+	}
+	if _, _, err := tr.Transact8x8Ctx(ctx, Addr7(0x50), 0x10, nil, make([]byte, 1)); err != nil {
+		t.Fatalf("Transact8x8Ctx failed: %v", err)
+	}
+	log := r.Log()
+	for i, e := range log {
+		if e.CorrelationID != "span-7" {
+			t.Fatalf("entry %d: expected correlation ID span-7, got %v", i, e)
+		}
+	}
+	if s := log[0].String(); !strings.Contains(s, " [span-7] START") {
+		t.Errorf("correlation ID missing from %q", s)
+	}
+
+	// transactions without an ID record none
+	r.Reset()
+	if _, _, err := tr.Transact8x8Ctx(context.Background(), Addr7(0x50), 0x10, nil, make([]byte, 1)); err != nil {
+		t.Fatalf("Transact8x8Ctx failed: %v", err)
+	}
+	for i, e := range r.Log() {
+		if e.CorrelationID != "" {
+			t.Fatalf("entry %d: expected no correlation ID, got %v", i, e)
+		}
+	}
+}
@@ -5,9 +5,13 @@
 package i2cm
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
+	"sync"
 	"time"
 )
 
@@ -15,6 +19,25 @@ const (
 	MAX_EEPROM_SIZE = 1 << (16 + 3)
 )
 
+// ErrReadOnly is returned by writes to EEPROM24s configured as read
+// only.
+var ErrReadOnly = errors.New("EEPROM24: read only")
+
+// ErrInternalAddressOverflow is returned by writes if the file pointer
+// or the position written ended up beyond the end of the memory array. This can only happen if
+// the configuration or the transactor is inconsistent.
+var ErrInternalAddressOverflow = errors.New("EEPROM24: wrote beyond end of EEPROM. is the configuration correct?")
+
+// addressModeThreshold is the size of the largest EEPROMs using the
+// 8+3 bit addressing convention, the 24C16. Larger EEPROMs, starting
+// with the 24C32, use the 16+3 bit convention.
+const addressModeThreshold = 1 << 11
+
+const (
+	defaultWriteTimeout = 25 * time.Millisecond
+	writePollInterval   = 500 * time.Microsecond
+)
+
 // EEPROM24Config is used to configure the EEPROM driver to use a
 // specific device. There are two protocols in use with 24Cxx devices:
 // 24C16 and smaller are addressed with 8+3 bits: 8 bits in the "register
@@ -26,31 +49,160 @@ const (
 type EEPROM24Config struct {
 	Size       uint
 	PageSize   uint
-	WriteDelay time.Duration // time to wait after a page write. Address polling is not implemented
+	WriteDelay time.Duration // time to wait after a page write, unless WriteReadyCheck or AckPolling is set
+
+	// WriteReadyCheck, if set, determines whether the device has
+	// completed the write cycle of a page. It is called with the
+	// transactor, which also implements the I2CMaster, and the device
+	// address used for the page after each page write, until it
+	// returns true, instead of waiting for WriteDelay. PollACK polls
+	// for the device's address ACK, other checks may read a status
+	// register.
+	WriteReadyCheck func(Transactor, Addr) (bool, error)
+
+	// WriteTimeout is the time after which a write fails if
+	// WriteReadyCheck did not report the device ready. Zero means 25 ms,
+	// or WriteDelay with AckPolling.
+	WriteTimeout time.Duration
+
+	// AckPolling makes writes wait for the write cycle of a page by
+	// probing the device like Ready until it ACKs its address, if
+	// WriteReadyCheck is not set. WriteDelay, the worst case write
+	// cycle time, then bounds the polling unless WriteTimeout is set.
+	AckPolling bool
+
+	// SkipUnchanged makes writes read each page before writing it and
+	// skip pages which already hold the data, to save write cycles.
+	// Skipped pages count as written.
+	SkipUnchanged bool
+
+	// ReadOnly makes all writes fail with ErrReadOnly without accessing
+	// the device.
+	ReadOnly bool
+
+	// Rollover makes Read continue at position 0 when it reaches the end
+	// of the array, like the sequential reads of the devices do, instead
+	// of returning io.EOF. Read then always fills its buffer. Writes
+	// still end at the end of the array.
+	Rollover bool
+
+	// ChecksumAddr is the position of a 16 bit checksum over the bytes
+	// [0, ChecksumAddr) of the array, maintained by WriteWithChecksum
+	// and checked by VerifyChecksum. The checksum is stored most
+	// significant byte first. Zero disables the checksum methods.
+	ChecksumAddr uint
+
+	// Checksum computes the checksum over the data region. If nil,
+	// CRC-16/XMODEM is used.
+	Checksum func([]byte) uint16
+
+	// FloatingRead, if set, is called with the position and length of
+	// every Read whose data LooksLikeFloatingRead, as a warning that
+	// the device may be absent or unpowered. The Read itself succeeds.
+	// Erased or zeroed regions trigger the warning as well.
+	FloatingRead func(off uint, n int)
+
+	// Log, if set, is called with the addressing decisions of Read,
+	// Write and Seek: the device address, register address and length
+	// of every transaction and the resulting file pointer, for
+	// debugging addressing problems. log.Printf fits.
+	Log func(format string, args ...interface{})
 }
 
-var Conf_24C02 = EEPROM24Config{256, 8, 5 * time.Millisecond}
-var Conf_24C128 = EEPROM24Config{16384, 64, 5 * time.Millisecond}
+var Conf_24C02 = EEPROM24Config{Size: 256, PageSize: 8, WriteDelay: 5 * time.Millisecond}
+var Conf_24C128 = EEPROM24Config{Size: 16384, PageSize: 64, WriteDelay: 5 * time.Millisecond}
 
 // ee24 supports 24Cxx family EEPROMs, both the 8+3 bit addressed
 // (24c16 and below) and the 16+3 bit addressed (24c32 and up) kind.
 // ee24 switches between the addressing modes at runtime, c.f. hasSmallAddresses.
+//
+// ee24 is safe for concurrent use: mu serializes all methods accessing
+// the device or the file pointer, so that every call, including a
+// multi-page write, is carried out as a whole.
 type ee24 struct {
+	mu sync.Mutex
+
 	conf    EEPROM24Config
 	m       I2CMaster
 	tr      Transactor
 	p       uint // file pointer
 	devaddr Addr
+
+	// statistics of the last Read or Write
+	lastn   int
+	lastdur time.Duration
 }
 
+// ee24 implements the optional interfaces of EEPROM24s.
+var (
+	_ EEPROM24 = (*ee24)(nil)
+	_ Syncer   = (*ee24)(nil)
+	_ Sizer    = (*ee24)(nil)
+)
+
 // EEPROM24 represents an I2C EEPROM device. The memory array is made
 // available via a file-like interface. The file's size is fixed to
 // the memory array size and writes past the end of the array result
 // in an error.
+//
+// Tell returns the position of the file pointer, which Seek(0, 1)
+// returns as well.
+//
+// Close writes out buffered data like Sync does. It does not close the
+// I2CMaster, which the EEPROM24 shares with other devices on the bus
+// and does not own.
 type EEPROM24 interface {
 	io.Reader
 	io.Seeker
 	io.Writer
+	io.Closer
+	io.ReaderAt
+	io.WriterAt
+	Tell() int64
+}
+
+// Syncer is implemented by EEPROM24s which may buffer written data.
+// Sync writes out all buffered data and waits for the device's final
+// write cycle to complete, so that the data is stored durably when Sync
+// returns without error.
+type Syncer interface {
+	Sync() error
+}
+
+// Sizer is implemented by EEPROM24s which report the size of their
+// memory array.
+type Sizer interface {
+	Size() int64
+}
+
+// eepromSize returns the size of e's memory array. If e is not a Sizer,
+// the size is determined by seeking to the end, after which e's file
+// pointer is restored.
+func eepromSize(e EEPROM24) (int64, error) {
+	if s, ok := e.(Sizer); ok {
+		return s.Size(), nil
+	}
+
+	cur := e.Tell()
+	if _, err := e.Seek(0, 2); err != nil {
+		return 0, err
+	}
+	size := e.Tell()
+	if _, err := e.Seek(cur, 0); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// lockedDo calls fn with e locked if e supports Locked, like the
+// EEPROM24s returned by NewEEPROM24, and with e itself otherwise.
+func lockedDo(e EEPROM24, fn func(EEPROM24) error) error {
+	if l, ok := e.(interface {
+		Locked(func(EEPROM24) error) error
+	}); ok {
+		return l.Locked(fn)
+	}
+	return fn(e)
 }
 
 func ispow2(i uint64) bool {
@@ -63,7 +215,19 @@ func ispow2(i uint64) bool {
 // NewEEPROM24 constructs an I2C EEPROM driver for a device with base
 // address devaddr residing on m's bus. The EEPROM driver parameters
 // are passed in conf. Invalid configurations are rejected.
+//
+// The EEPROM24 returned is safe for concurrent use. Each call is
+// atomic with respect to other calls on it. Sequences of calls relying
+// on the file pointer, like Seek followed by Read, need to be carried
+// out by its Locked method or be replaced by ReadAt and WriteAt. The
+// driver assumes that m is used only by it while a call is in
+// progress; other users of the bus need to synchronize with it
+// themselves.
 func NewEEPROM24(m I2CMaster, devaddr Addr, conf EEPROM24Config) (EEPROM24, error) {
+	if conf.Size == 0 || conf.PageSize == 0 {
+		return nil, errors.New("EEPROM24: configuration is empty; Size and PageSize must be set")
+	}
+
 	if conf.PageSize > conf.Size {
 		return nil, errors.New("EEPROM24: page size needs to be smaller than array size")
 	}
@@ -84,6 +248,10 @@ func NewEEPROM24(m I2CMaster, devaddr Addr, conf EEPROM24Config) (EEPROM24, erro
 		return nil, errors.New("only EEPROMs with 7 bit device addresses are supported")
 	}
 
+	if conf.ChecksumAddr != 0 && conf.ChecksumAddr+2 > conf.Size {
+		return nil, errors.New("EEPROM24: checksum needs to be located inside the array")
+	}
+
 	var e ee24
 
 	e.m = m
@@ -100,17 +268,303 @@ func NewEEPROM24(m I2CMaster, devaddr Addr, conf EEPROM24Config) (EEPROM24, erro
 // use the small addressing convention, 24c32 and up use the big addressing
 // convention, i.e. the 16 bit + 3 bit one.
 func (e EEPROM24Config) hasSmallAddresses() bool {
-	if e.Size <= (1 << 11) {
+	if e.Size <= addressModeThreshold {
 		return true
 	}
 	return false
 }
 
+// Ready probes the EEPROM with a single address write. It returns true
+// if the device ACKed, i.e. it is idle, and false if it NACKed, which
+// a 24Cxx device does while its internal write cycle is in progress.
+// Bus errors other than a NACK are returned as is. The result is
+// meaningless for absent devices or devices which are not EEPROMs, as
+// they always NACK or always ACK, respectively.
+func (e *ee24) Ready() (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return probe(e.m, e.devaddr)
+}
+
+// Sync implements Syncer. ee24 does not buffer written data, so
+// there is nothing to do.
+func (e *ee24) Sync() error {
+	return nil
+}
+
+// lockedEE24 carries out the EEPROM24 methods of an ee24 whose mutex
+// is already held, c.f. Locked.
+type lockedEE24 struct {
+	e *ee24
+}
+
+func (l lockedEE24) Close() error {
+	return l.e.Sync()
+}
+
+func (l lockedEE24) Size() int64 {
+	return l.e.Size()
+}
+
+func (l lockedEE24) pageSize() uint {
+	return l.e.pageSize()
+}
+
+// Locked calls fn with e locked and passes it an EEPROM24 accessing e,
+// so that a sequence of calls, e.g. Seek followed by Read, is carried
+// out without interference from calls on e by other goroutines. The
+// EEPROM24 passed to fn must not be used after fn returned, and fn must
+// not call methods of e itself. Locked returns fn's error.
+func (e *ee24) Locked(fn func(EEPROM24) error) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return fn(lockedEE24{e})
+}
+
+// Close implements io.Closer. As Sync, it has nothing to do for ee24.
+// The I2CMaster is left open.
+func (e *ee24) Close() error {
+	return e.Sync()
+}
+
+// Size implements Sizer.
+func (e *ee24) Size() int64 {
+	return int64(e.conf.Size)
+}
+
+func (l lockedEE24) Tell() int64 {
+	return int64(l.e.p)
+}
+
+// Tell implements EEPROM24.
+func (e *ee24) Tell() int64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return lockedEE24{e}.Tell()
+}
+
+// Remaining returns the number of bytes between the file pointer and
+// the end of the array, i.e. the number of bytes which can be read or
+// written before reaching EOF.
+func (e *ee24) Remaining() int64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return int64(e.conf.Size - e.p)
+}
+
+// Checkpoint captures the file pointer and returns a function which
+// restores it, so that helpers which move the pointer can
+// defer e.Checkpoint()().
+func (e *ee24) Checkpoint() func() {
+	e.mu.Lock()
+	p := e.p
+	e.mu.Unlock()
+
+	return func() {
+		e.mu.Lock()
+		e.p = p
+		e.mu.Unlock()
+	}
+}
+
+// logf logs through the configured Log function, if any.
+func (e *ee24) logf(format string, args ...interface{}) {
+	if e.conf.Log != nil {
+		e.conf.Log(format, args...)
+	}
+}
+
+// pageSize returns the size of the device's pages.
+func (e *ee24) pageSize() uint {
+	return e.conf.PageSize
+}
+
+// LastOpStats returns the number of bytes transferred by the most
+// recent Read or Write and the wall-clock time it took, including the
+// time spent waiting for write cycles to complete.
+func (e *ee24) LastOpStats() (bytes int, dur time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.lastn, e.lastdur
+}
+
+func (e *ee24) recordOp(n int, start time.Time) {
+	e.lastn = n
+	e.lastdur = time.Since(start)
+}
+
+// bankSize returns the number of bytes addressed by one device
+// address.
+func (e EEPROM24Config) bankSize() uint {
+	if e.hasSmallAddresses() {
+		return 1 << 8
+	}
+	return 1 << 16
+}
+
+// BankMapping describes which part of the memory array is accessed via
+// a device address.
+type BankMapping struct {
+	Phys  Addr7   // device address on the bus
+	Range [2]uint // logical byte range [Range[0], Range[1]) of the array
+}
+
+// AddressMap returns how the memory array of a device at base address
+// base is spread across device addresses, in ascending order. 24C04 to
+// 24C16 devices occupy up to eight device addresses, all other devices
+// occupy one, unless they are larger than 64 KiB.
+func (e EEPROM24Config) AddressMap(base Addr7) []BankMapping {
+	bank := e.bankSize()
+	if bank > e.Size {
+		bank = e.Size
+	}
+
+	var m []BankMapping
+	for start := uint(0); start < e.Size; start += bank {
+		phys := Addr7(uint8(base.GetBaseAddr() + uint16(start/e.bankSize())))
+		m = append(m, BankMapping{phys, [2]uint{start, start + bank}})
+	}
+	return m
+}
+
+func (l lockedEE24) Read(b []byte) (int, error) {
+	e := l.e
+	start := time.Now()
+	p := e.p
+	n, err := e.read(b)
+	e.recordOp(n, start)
+	if e.conf.FloatingRead != nil && LooksLikeFloatingRead(b[:n]) {
+		e.conf.FloatingRead(p, n)
+	}
+	return n, err
+}
+
 func (e *ee24) Read(b []byte) (int, error) {
-	// TODO: does read address roll over at the end of the
-	// memory array or every 256 bytes?
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return lockedEE24{e}.Read(b)
+}
+
+func (l lockedEE24) ReadAt(b []byte, off int64) (int, error) {
+	e := l.e
+	if off < 0 {
+		return 0, errors.New("EEPROM24.ReadAt: negative offset")
+	}
+	if off >= int64(e.conf.Size) {
+		return 0, io.EOF
+	}
+
+	start := time.Now()
+	n, err := e.readAt(b, uint(off))
+	e.recordOp(n, start)
+	if e.conf.FloatingRead != nil && LooksLikeFloatingRead(b[:n]) {
+		e.conf.FloatingRead(uint(off), n)
+	}
+	if err == nil && n < len(b) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// ReadAt implements io.ReaderAt. It reads len(b) bytes from position
+// off of the memory array without touching the file pointer. If the end
+// of the array is reached before b is filled, io.EOF is returned along
+// with the bytes read. Rollover does not apply to ReadAt.
+func (e *ee24) ReadAt(b []byte, off int64) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return lockedEE24{e}.ReadAt(b, off)
+}
+
+// floatingReadMinLen is the length from which LooksLikeFloatingRead
+// considers uniform data suspicious.
+const floatingReadMinLen = 16
+
+// LooksLikeFloatingRead reports whether b, read from a device, consists
+// of at least 16 bytes which are all 0xff or all 0x00. This is what a
+// read returns when the device does not drive the bus, e.g. because it
+// is absent or not powered, and the bus floats high or is held low.
+// Shorter reads are never reported, as uniform short runs are common
+// in real data. Note that erased EEPROM regions hold all 0xff and are
+// reported as well.
+func LooksLikeFloatingRead(b []byte) bool {
+	if len(b) < floatingReadMinLen || (b[0] != 0x00 && b[0] != 0xff) {
+		return false
+	}
+	for _, v := range b {
+		if v != b[0] {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *ee24) read(b []byte) (int, error) {
+	if e.conf.Rollover {
+		return e.readRollover(b)
+	}
+
+	n, err := e.readAt(b, e.p)
+	e.p += uint(n)
+	return n, err
+}
+
+// ReadFull reads exactly len(b) bytes from the file pointer into b,
+// like io.ReadFull: if the end of the array is reached after some but
+// not all bytes, io.ErrUnexpectedEOF is returned, io.EOF only if no
+// byte could be read. The file pointer is advanced by the bytes read.
+func (e *ee24) ReadFull(b []byte) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	nr := 0
+	for nr < len(b) {
+		n, err := e.readAt(b[nr:], e.p)
+		nr += n
+		e.p += uint(n)
+		if err == io.EOF {
+			if nr > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return nr, err
+		}
+		if err != nil {
+			return nr, err
+		}
+	}
+	return nr, nil
+}
 
-	startpos := e.p
+// readRollover reads like read, continuing at position 0 at the end of
+// the array. Every round reads at least one byte up to the end of the
+// array, so it takes len(b)/Size+2 rounds at most.
+func (e *ee24) readRollover(b []byte) (int, error) {
+	nr := 0
+	for nr < len(b) {
+		if e.p == e.conf.Size {
+			e.p = 0
+		}
+		n, err := e.readAt(b[nr:], e.p)
+		nr += n
+		e.p += uint(n)
+		if err != nil {
+			return nr, err
+		}
+	}
+	return nr, nil
+}
+
+// readAt reads into b from the memory array at position pos without
+// touching the file pointer.
+func (e *ee24) readAt(b []byte, pos uint) (int, error) {
+	startpos := pos
 	endpos := startpos + uint(len(b))
 	if endpos > e.conf.Size {
 		endpos = e.conf.Size
@@ -121,33 +575,187 @@ func (e *ee24) Read(b []byte) (int, error) {
 	}
 
 	rb := b[0:(endpos - startpos)]
-	var nr int
-	var err error
+	nr := 0
 
-	// devaddrinc is protected from overflow by the read/write/seek logic
-	// more protection might still be desirable though
+	// reads are not constrained by pages, but the register address does
+	// not carry over into the device address. so there is one
+	// transaction per bank of memory addressed by one device address.
 	if e.conf.hasSmallAddresses() {
-		devaddrinc := startpos >> 8 // 256 byte every 1 7-bit slave addr
-		devaddr := Addr7(uint8(e.devaddr.GetBaseAddr() + uint16(devaddrinc)))
+		base := Addr7(uint8(e.devaddr.GetBaseAddr()))
+		for _, st := range SmallAddrReadPlan(startpos, uint(len(rb)), base) {
+			e.logf("EEPROM24: read %d bytes at %#x: device %v, register %#02x", st.Len, startpos+uint(nr), st.Dev, st.Reg)
+			_, n, err := e.tr.Transact8x8(st.Dev, st.Reg, nil, rb[nr:nr+int(st.Len)])
+			nr += n
+			if err != nil {
+				return nr, err
+			}
+		}
+		return nr, nil
+	}
 
-		regaddr := uint8(startpos & 0xff)
+	for nr < len(rb) {
+		pos := startpos + uint(nr)
+		chunk := rb[nr:]
+		if rem := 1<<16 - (pos & 0xffff); uint(len(chunk)) > rem {
+			chunk = chunk[:rem]
+		}
 
-		_, nr, err = e.tr.Transact8x8(devaddr, regaddr, nil, rb)
-	} else {
-		devaddrinc := startpos >> 16 // 256 bytes every 1 7-bit slave addr
-		devaddr := Addr7(uint8(e.devaddr.GetBaseAddr() + uint16(devaddrinc)))
+		devaddr, regaddr := e.physAddr(pos)
 
-		regaddr := uint16(startpos)
+		e.logf("EEPROM24: read %d bytes at %#x: device %v, register %#04x", len(chunk), pos, devaddr, regaddr)
+		_, n, err := e.tr.Transact16x8(devaddr, regaddr, nil, chunk)
+		nr += n
+		if err != nil {
+			return nr, err
+		}
+	}
 
-		_, nr, err = e.tr.Transact16x8(devaddr, regaddr, nil, rb)
+	return nr, nil
+}
+
+// SmallAddrRead is a read transaction of a SmallAddrReadPlan.
+type SmallAddrRead struct {
+	Dev Addr7 // device address
+	Reg uint8 // register address, i.e. the position within the bank
+	Len uint  // number of bytes to read
+}
+
+// SmallAddrReadPlan splits a read of n bytes at position startPos of
+// the memory array of a device with 8+3 bit addresses, c.f.
+// EEPROM24Config, at base address baseAddr into transactions. As the
+// register address wraps around within a bank of 256 bytes, there is
+// one transaction per bank touched, at device address baseAddr plus the
+// bank number. The plan is empty for n == 0.
+func SmallAddrReadPlan(startPos, n uint, baseAddr Addr7) []SmallAddrRead {
+	var plan []SmallAddrRead
+	for pos, end := startPos, startPos+n; pos < end; {
+		l := 256 - (pos & 0xff)
+		if l > end-pos {
+			l = end - pos
+		}
+		plan = append(plan, SmallAddrRead{
+			Dev: Addr7(uint8(baseAddr.GetBaseAddr() + uint16(pos>>8))),
+			Reg: uint8(pos),
+			Len: l,
+		})
+		pos += l
 	}
+	return plan
+}
 
-	e.p += uint(nr)
+// ReadCurrentAddress reads into b from the device's internal address
+// pointer, which points past the byte accessed last, without writing
+// the address first, and advances the file pointer by the bytes read.
+// This saves the address bytes when reading sequentially, but is only
+// correct while the device's pointer and the file pointer agree, i.e.
+// right after a Read or Write through e. After a Seek, or an access by
+// other code, the device's pointer is elsewhere and ReadCurrentAddress
+// returns the wrong data. It needs a low level I2CMaster.
+func (e *ee24) ReadCurrentAddress(b []byte) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.p >= e.conf.Size {
+		if len(b) == 0 {
+			return 0, nil
+		}
+		return 0, io.EOF
+	}
+	if rem := e.conf.Size - e.p; uint(len(b)) > rem {
+		b = b[:rem]
+	}
 
-	return nr, err
+	devaddr := Addr7(uint8(e.devaddr.GetBaseAddr() + uint16(e.p/e.conf.bankSize())))
+	n, err := currentAddressRead(e.m, devaddr, b)
+	e.p += uint(n)
+	return n, err
 }
 
-func (e *ee24) Seek(offset int64, whence int) (int64, error) {
+// readUntilChunk is the number of bytes ReadUntilByte reads per
+// transaction.
+const readUntilChunk = 32
+
+// ReadUntilByte reads from the file pointer up to and including the
+// first occurrence of delim, but at most maxLen bytes, and advances the
+// file pointer past the bytes returned. The array is read in chunks,
+// so bytes past delim may be read from the device. If the end of the
+// array is reached before delim or maxLen, the bytes read are returned
+// along with io.EOF.
+func (e *ee24) ReadUntilByte(delim byte, maxLen int) ([]byte, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var out []byte
+	chunk := make([]byte, readUntilChunk)
+
+	for len(out) < maxLen {
+		n := maxLen - len(out)
+		if n > len(chunk) {
+			n = len(chunk)
+		}
+
+		nr, err := e.readAt(chunk[:n], e.p)
+		if i := bytes.IndexByte(chunk[:nr], delim); i >= 0 {
+			out = append(out, chunk[:i+1]...)
+			e.p += uint(i + 1)
+			return out, nil
+		}
+		out = append(out, chunk[:nr]...)
+		e.p += uint(nr)
+
+		if err != nil {
+			return out, err
+		}
+		if nr < n {
+			// reached the end of the array
+			return out, io.EOF
+		}
+	}
+
+	return out, nil
+}
+
+// ReadReverse reads the bytes at offsets startOff, startOff-1, ... into
+// b, i.e. b[0] holds the byte at startOff, b[1] the one before and so
+// on. As the devices only read in ascending order, the bytes are read
+// forward and reversed in b. If the beginning of the array is reached
+// before b is filled, the number of bytes read is returned along with
+// io.EOF. The file pointer is not changed.
+func (e *ee24) ReadReverse(b []byte, startOff uint) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if startOff >= e.conf.Size {
+		return 0, errors.New("EEPROM24.ReadReverse: start offset beyond end of EEPROM array")
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	n := uint(len(b))
+	if n > startOff+1 {
+		n = startOff + 1
+	}
+
+	nr, err := e.readAt(b[:n], startOff+1-n)
+	if err != nil {
+		// the bytes read are the lowest ones, which do not belong
+		// at the start of b
+		return 0, err
+	}
+
+	for i, j := 0, nr-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+
+	if nr < len(b) {
+		return nr, io.EOF
+	}
+	return nr, nil
+}
+
+func (l lockedEE24) Seek(offset int64, whence int) (int64, error) {
+	e := l.e
 	P := int64(e.p)
 
 	// this may fail in funny ways for big absolute values of offset.
@@ -160,76 +768,612 @@ func (e *ee24) Seek(offset int64, whence int) (int64, error) {
 	case 2:
 		nP = int64(e.conf.Size) + offset
 	default:
-		return P, errors.New("EEPROM24.Seek: invalid whence")
+		return 0, errors.New("EEPROM24.Seek: invalid whence")
 	}
 
 	if nP < 0 {
-		return P, errors.New("EEPROM24.Seek: negative position")
+		return 0, errors.New("EEPROM24.Seek: negative position")
 	}
 
 	if nP > int64(e.conf.Size) {
-		return P, errors.New("EEPROM24.Seek: desired position beyond end of EEPROM array")
+		return 0, errors.New("EEPROM24.Seek: desired position beyond end of EEPROM array")
 	}
 
 	e.p = uint(nP)
+	e.logf("EEPROM24: seek from %#x to %#x", P, nP)
 
-	return P, nil
+	return nP, nil
+}
+
+func (e *ee24) Seek(offset int64, whence int) (int64, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return lockedEE24{e}.Seek(offset, whence)
+}
+
+// WriteMode selects how the completion of write cycles is waited for.
+// The zero value selects the configured behavior, c.f. WriteDelay and
+// WriteReadyCheck of EEPROM24Config.
+type WriteMode struct {
+	kind  int
+	delay time.Duration
+}
+
+const (
+	writeModeDefault = iota
+	writeModeDelay
+	writeModePollACK
+)
+
+// WriteModePollACK polls for write cycle completion by probing the
+// device like Ready.
+var WriteModePollACK = WriteMode{kind: writeModePollACK}
+
+// WriteModeDelay waits for d after each page write. Zero does not wait
+// at all, e.g. for FRAM regions.
+func WriteModeDelay(d time.Duration) WriteMode {
+	return WriteMode{kind: writeModeDelay, delay: d}
+}
+
+// WriteContext is like Write, but stops waiting for write cycles and
+// returns ctx's error when ctx is done. The bytes of the page whose
+// write cycle was waited for count as written, though the device may
+// not have completed storing them.
+func (e *ee24) WriteContext(ctx context.Context, b []byte) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	start := time.Now()
+	n, err := e.writeWith(ctx, b, WriteMode{})
+	e.recordOp(n, start)
+	return n, err
+}
+
+// WriteWith is like Write, but waits for write cycles as selected by
+// mode instead of as configured. This allows memory regions with
+// different write cycle behavior to be written with one EEPROM24.
+func (e *ee24) WriteWith(b []byte, mode WriteMode) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if mode.kind < writeModeDefault || mode.kind > writeModePollACK || mode.delay < 0 {
+		return 0, errors.New("EEPROM24.WriteWith: invalid write mode")
+	}
+
+	start := time.Now()
+	n, err := e.writeWith(context.Background(), b, mode)
+	e.recordOp(n, start)
+	return n, err
+}
+
+func (l lockedEE24) Write(b []byte) (int, error) {
+	e := l.e
+	start := time.Now()
+	n, err := e.write(b)
+	e.recordOp(n, start)
+	return n, err
 }
 
 func (e *ee24) Write(b []byte) (int, error) {
-	origsize := len(b)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return lockedEE24{e}.Write(b)
+}
+
+func (l lockedEE24) WriteAt(b []byte, off int64) (int, error) {
+	e := l.e
+	if off < 0 {
+		return 0, errors.New("EEPROM24.WriteAt: negative offset")
+	}
+	if off > int64(e.conf.Size) {
+		off = int64(e.conf.Size)
+	}
 
-	for len(b) > 0 && e.p < e.conf.Size {
+	start := time.Now()
+	n, err := e.writeAt(b, uint(off))
+	e.recordOp(n, start)
+	return n, err
+}
 
-		// address in page
-		aip := e.p & (e.conf.PageSize - 1)
-		//log.Printf("e.p %#04x  aip %#02x\n", e.p, aip)
-		// get number of bytes to write in this page
-		nip := uint(len(b))
-		if nip > e.conf.PageSize-aip {
-			nip = e.conf.PageSize - aip
+// WriteAt implements io.WriterAt. It writes b to position off of the
+// memory array like Write, page by page, without touching the file
+// pointer. Writes end at the end of the array with io.EOF.
+func (e *ee24) WriteAt(b []byte, off int64) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return lockedEE24{e}.WriteAt(b, off)
+}
+
+func (e *ee24) write(b []byte) (int, error) {
+	return e.writeWith(context.Background(), b, WriteMode{})
+}
+
+// writeWith writes b at the file pointer and advances it, waiting for
+// write cycles as selected by mode.
+func (e *ee24) writeWith(ctx context.Context, b []byte, mode WriteMode) (int, error) {
+	n, err := e.writeAtWith(ctx, b, e.p, mode)
+	e.p += uint(n)
+	return n, err
+}
+
+// writeAt writes b to the memory array at position p without touching
+// the file pointer.
+func (e *ee24) writeAt(b []byte, p uint) (int, error) {
+	return e.writeAtWith(context.Background(), b, p, WriteMode{})
+}
+
+// writeAtWith is writeAt waiting for write cycles as selected by mode.
+// Waiting is aborted if ctx is done. All writes end up here, so that a
+// position or a count of bytes written beyond the end of the array is
+// reported as ErrInternalAddressOverflow on every path.
+func (e *ee24) writeAtWith(ctx context.Context, b []byte, p uint, mode WriteMode) (int, error) {
+	if p > e.conf.Size {
+		return 0, ErrInternalAddressOverflow
+	}
+
+	n, err := e.writePages(ctx, b, p, mode)
+	if p+uint(n) > e.conf.Size {
+		// the transactor reported more bytes than there are left. all
+		// transactions are complete, so the bus is idle.
+		return int(e.conf.Size - p), ErrInternalAddressOverflow
+	}
+	return n, err
+}
+
+// writePages carries out the page writes of writeAtWith.
+func (e *ee24) writePages(ctx context.Context, b []byte, p uint, mode WriteMode) (int, error) {
+	if e.conf.ReadOnly {
+		return 0, ErrReadOnly
+	}
+
+	// writes end at the end of the array
+	n := uint(len(b))
+	if p >= e.conf.Size {
+		n = 0
+	} else if n > e.conf.Size-p {
+		n = e.conf.Size - p
+	}
+
+	written := 0
+	for _, pw := range e.pageWrites(p, n) {
+		chunk := b[written : written+int(pw.Len)]
+
+		if e.conf.SkipUnchanged {
+			same, err := e.unchanged(chunk, pw.Off)
+			if err != nil {
+				return written, err
+			}
+			if same {
+				written += len(chunk)
+				continue
+			}
 		}
 
-		// do transaction
-		//log.Printf("at p %#04x, pagesize %#02x read nip %#02x\n", e.p, e.PageSize, nip)
+		e.logf("EEPROM24: write %d bytes at %#x: device %v, register %#02x, page %#x", pw.Len, pw.Off, pw.Dev, pw.Reg, pw.Off&^(e.conf.PageSize-1))
 		var nw int
 		var err error
-
 		if e.conf.hasSmallAddresses() {
-			regaddr := uint8(e.p & 0xff)
-			devaddrinc := e.p >> 8 // 256 byte every 1 7-bit slave addr
-			devaddr := Addr7(uint8(e.devaddr.GetBaseAddr() + uint16(devaddrinc)))
-
-			nw, _, err = e.tr.Transact8x8(devaddr, regaddr, b[0:nip], nil)
+			nw, _, err = e.tr.Transact8x8(pw.Dev, uint8(pw.Reg), chunk, nil)
 		} else {
-			regaddr := uint16(e.p)
-			devaddrinc := e.p >> 16 // 256 bytes every 1 7-bit slave addr
-			devaddr := Addr7(uint8(e.devaddr.GetBaseAddr() + uint16(devaddrinc)))
+			nw, _, err = e.tr.Transact16x8(pw.Dev, pw.Reg, chunk, nil)
+		}
 
-			nw, _, err = e.tr.Transact16x8(devaddr, regaddr, b[0:nip], nil)
+		if err == nil && nw < len(chunk) {
+			// the transactor violated its contract, but a short write
+			// must never be reported without an error
+			err = io.ErrShortWrite
 		}
 
 		if err != nil {
-			return origsize - len(b) + nw, err
+			// bytes written before the error are accounted for
+			return written + nw, err
 		}
 
-		// TODO: either wait or poll for device
+		// the write cycle of the page is completed before the next page
+		// is written, so that pages, and thus banks, are written in
+		// ascending order and an interrupted write leaves a prefix of b
+		// written.
+		if err := e.waitWriteCycle(ctx, pw.Off, mode); err != nil {
+			return written + nw, err
+		}
 
-		e.p += uint(nip)
-		b = b[nip:]
+		written += len(chunk)
 	}
 
-	//log.Printf("at end of write, p %d  len(b) %d\n", e.p, len(b))
-
-	if e.p == e.conf.Size {
+	if written < len(b) {
 		// reached the end of the array
-		if len(b) > 0 {
-			return origsize - len(b), io.EOF
+		return written, io.EOF
+	}
+
+	return written, nil
+}
+
+// PhysicalAddr returns the device address and the register address
+// within the device which position off of the memory array is accessed
+// at. off needs to lie within the array.
+func (e *ee24) PhysicalAddr(off uint) (dev Addr7, reg uint16, err error) {
+	if off >= e.conf.Size {
+		return 0, 0, fmt.Errorf("EEPROM24.PhysicalAddr: position %d beyond the end of the array of %d bytes", off, e.conf.Size)
+	}
+	dev, reg = e.physAddr(off)
+	return dev, reg, nil
+}
+
+// physAddr returns the device and register address of position p,
+// which is not checked against the array size. 8+3 bit addressed
+// devices occupy one device address every 256 bytes, 16+3 bit
+// addressed ones every 64 KiB.
+func (e *ee24) physAddr(p uint) (Addr7, uint16) {
+	if e.conf.hasSmallAddresses() {
+		return Addr7(uint8(e.devaddr.GetBaseAddr() + uint16(p>>8))), uint16(p & 0xff)
+	}
+	return Addr7(uint8(e.devaddr.GetBaseAddr() + uint16(p>>16))), uint16(p)
+}
+
+// PageWrite is a page write transaction of a WritePlan.
+type PageWrite struct {
+	Dev Addr7  // device address
+	Reg uint16 // register address, 8 bit for 8+3 bit addressed devices
+	Off uint   // position in the memory array
+	Len uint   // number of bytes written
+}
+
+// WritePlan describes the transactions a write carries out.
+type WritePlan struct {
+	Writes []PageWrite
+
+	// Duration is the estimated time spent waiting for write cycles,
+	// i.e. WriteDelay per page written.
+	Duration time.Duration
+}
+
+// PlanWrite returns the page writes which writing data at position off
+// of the memory array carries out, in order, without accessing the bus.
+// Unlike Write, which writes up to the end of the array, PlanWrite
+// fails if data does not fit into the array. With SkipUnchanged, Write
+// may skip some of the planned pages.
+func (e *ee24) PlanWrite(off uint, data []byte) (WritePlan, error) {
+	if e.conf.ReadOnly {
+		return WritePlan{}, ErrReadOnly
+	}
+	if off > e.conf.Size || uint(len(data)) > e.conf.Size-off {
+		return WritePlan{}, fmt.Errorf("EEPROM24.PlanWrite: %d bytes at position %d exceed the array size of %d bytes", len(data), off, e.conf.Size)
+	}
+
+	writes := e.pageWrites(off, uint(len(data)))
+	return WritePlan{
+		Writes:   writes,
+		Duration: time.Duration(len(writes)) * e.conf.WriteDelay,
+	}, nil
+}
+
+// pageWrites splits a write of n bytes at position p into one
+// transaction per page touched. p+n must not exceed the array size.
+func (e *ee24) pageWrites(p, n uint) []PageWrite {
+	var writes []PageWrite
+	for end := p + n; p < end; {
+		// address in page
+		aip := p & (e.conf.PageSize - 1)
+		// number of bytes to write in this page
+		nip := e.conf.PageSize - aip
+		if nip > end-p {
+			nip = end - p
 		}
+
+		dev, reg := e.physAddr(p)
+		writes = append(writes, PageWrite{Dev: dev, Reg: reg, Off: p, Len: nip})
+		p += nip
 	}
-	if e.p > e.conf.Size {
-		panic("wrote beyond end of EEPROM. is the configuration correct?")
+	return writes
+}
+
+// ApplyPatch brings the memory array to hold newImage at its beginning
+// by writing only the pages which differ from newImage. The region
+// covered by newImage is read in full first. newImage may be shorter
+// than the array, the rest of the array is left alone. ApplyPatch
+// returns the number of pages written. The file pointer is not changed.
+func (e *ee24) ApplyPatch(newImage []byte) (pagesWritten int, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if uint(len(newImage)) > e.conf.Size {
+		return 0, fmt.Errorf("EEPROM24.ApplyPatch: image of %d bytes exceeds the array size of %d bytes", len(newImage), e.conf.Size)
+	}
+
+	cur := make([]byte, len(newImage))
+	if len(cur) > 0 {
+		if _, err := e.readAt(cur, 0); err != nil {
+			return 0, err
+		}
+	}
+
+	for off := uint(0); off < uint(len(newImage)); off += e.conf.PageSize {
+		end := off + e.conf.PageSize
+		if end > uint(len(newImage)) {
+			end = uint(len(newImage))
+		}
+		if string(cur[off:end]) == string(newImage[off:end]) {
+			continue
+		}
+
+		if _, err := e.writeAt(newImage[off:end], off); err != nil {
+			return pagesWritten, err
+		}
+		pagesWritten++
 	}
 
-	return origsize, nil
+	return pagesWritten, nil
+}
+
+// unchanged reports whether the memory array at position p already
+// holds b.
+func (e *ee24) unchanged(b []byte, p uint) (bool, error) {
+	cur := make([]byte, len(b))
+	if _, err := e.readAt(cur, p); err != nil {
+		return false, err
+	}
+	return string(cur) == string(b), nil
+}
+
+// waitWriteCycle waits for the write cycle of the page containing
+// position p to complete as selected by mode, c.f.
+// EEPROM24Config.WriteReadyCheck. Waiting is aborted with ctx's error
+// if ctx is done.
+func (e *ee24) waitWriteCycle(ctx context.Context, p uint, mode WriteMode) error {
+	check := e.conf.WriteReadyCheck
+	poll := check == nil && e.conf.AckPolling
+	delay := e.conf.WriteDelay
+	switch mode.kind {
+	case writeModeDelay:
+		check, poll, delay = nil, false, mode.delay
+	case writeModePollACK:
+		poll = true
+	}
+	if poll {
+		check = PollACK
+	}
+
+	if check == nil {
+		if delay > 0 {
+			return sleepContext(ctx, delay)
+		}
+		return nil
+	}
+
+	devaddr := Addr7(uint8(e.devaddr.GetBaseAddr() + uint16(p/e.conf.bankSize())))
+
+	timeout := e.conf.WriteTimeout
+	if timeout == 0 && e.conf.AckPolling {
+		timeout = e.conf.WriteDelay
+	}
+	if timeout == 0 {
+		timeout = defaultWriteTimeout
+	}
+	deadline := time.Now().Add(timeout)
+
+	for {
+		ready, err := check(masterTransactor{e.tr, e.m}, devaddr)
+		if err != nil {
+			return err
+		}
+		if ready {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("EEPROM24: write cycle did not complete within %v", timeout)
+		}
+		if err := sleepContext(ctx, writePollInterval); err != nil {
+			return err
+		}
+	}
+}
+
+// sleepContext waits for d or until ctx is done, in which case it
+// returns ctx's error.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if ctx.Done() == nil {
+		sleep(d)
+		return nil
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// masterTransactor is a Transactor which also provides the I2CMaster it
+// is based on, e.g. for PollACK.
+type masterTransactor struct {
+	Transactor
+	I2CMaster
+}
+
+// PollACK is a WriteReadyCheck which probes the device like Ready and
+// reports it as ready if it ACKs its address, as 24Cxx devices do not
+// ACK their address while a write cycle is in progress. The EEPROM24
+// drivers pass a tr which also implements I2CMaster. Other transactors
+// can only address the device with an empty write to register 0.
+func PollACK(tr Transactor, addr Addr) (bool, error) {
+	if m, ok := tr.(I2CMaster); ok {
+		return probe(m, addr)
+	}
+
+	_, _, err := tr.Transact8x8(addr, 0, nil, nil)
+	switch err {
+	case nil:
+		return true, nil
+	case NoSuchDevice, NACKReceived:
+		return false, nil
+	}
+	return false, err
+}
+
+// WriteWords16 writes words to the array at position off, each word
+// serialized as two bytes in byte order order. A word may straddle a
+// page boundary. WriteWords16 returns the number of words written
+// completely. The file pointer is not changed.
+func (e *ee24) WriteWords16(off uint, words []uint16, order binary.ByteOrder) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	b := make([]byte, 2*len(words))
+	for i, w := range words {
+		order.PutUint16(b[2*i:], w)
+	}
+	n, err := e.writeAt(b, off)
+	return n / 2, err
+}
+
+// ReadWords16 reads len(words) words from the array at position off,
+// each word deserialized from two bytes in byte order order.
+// ReadWords16 returns the number of words read completely. If the end
+// of the array is reached first, the error is io.EOF. The file pointer
+// is not changed.
+func (e *ee24) ReadWords16(off uint, words []uint16, order binary.ByteOrder) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	b := make([]byte, 2*len(words))
+	n, err := e.readAt(b, off)
+	for i := 0; i < n/2; i++ {
+		words[i] = order.Uint16(b[2*i:])
+	}
+	if err == nil && n < len(b) {
+		err = io.EOF
+	}
+	return n / 2, err
+}
+
+// IncrementCounter increments the unsigned counter of width bytes,
+// 1 to 8, in byte order order at position off of the array and returns
+// the new value. A counter at its maximum value is not changed and an
+// error is returned. The file pointer is not changed.
+//
+// The increment is not atomic: a power loss during the write may leave
+// some bytes of the counter written and others not, in particular if the
+// counter straddles a page boundary and is written in two write cycles.
+// Applications relying on the counter should keep a redundant copy or a
+// checksum.
+func (e *ee24) IncrementCounter(off uint, width int, order binary.ByteOrder) (uint64, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if width < 1 || width > 8 {
+		return 0, fmt.Errorf("EEPROM24.IncrementCounter: invalid counter width of %d bytes", width)
+	}
+	if off > e.conf.Size || uint(width) > e.conf.Size-off {
+		return 0, fmt.Errorf("EEPROM24.IncrementCounter: counter at position %d exceeds the array size of %d bytes", off, e.conf.Size)
+	}
+
+	b := make([]byte, width)
+	if _, err := e.readAt(b, off); err != nil {
+		return 0, err
+	}
+
+	// index the bytes from the least significant one
+	bigEndian := order.Uint16([]byte{0, 1}) == 1
+	at := func(i int) *byte {
+		if bigEndian {
+			return &b[width-1-i]
+		}
+		return &b[i]
+	}
+
+	i := 0
+	for ; i < width; i++ {
+		*at(i)++
+		if *at(i) != 0 {
+			break
+		}
+	}
+	if i == width {
+		return 0, fmt.Errorf("EEPROM24.IncrementCounter: counter at position %d overflows", off)
+	}
+
+	if _, err := e.writeAt(b, off); err != nil {
+		return 0, err
+	}
+
+	var v uint64
+	for i := width - 1; i >= 0; i-- {
+		v = v<<8 | uint64(*at(i))
+	}
+	return v, nil
+}
+
+var errNoChecksum = errors.New("EEPROM24: no checksum configured")
+
+// WriteWithChecksum writes data to the array at position off and then
+// updates the checksum at ChecksumAddr to cover the new data. data
+// needs to lie within the checksummed region. The file pointer is not
+// changed. Note that a power loss between the two writes leaves the
+// checksum stale.
+func (e *ee24) WriteWithChecksum(off uint, data []byte) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.conf.ChecksumAddr == 0 {
+		return errNoChecksum
+	}
+
+	if off+uint(len(data)) > e.conf.ChecksumAddr {
+		return errors.New("EEPROM24.WriteWithChecksum: data overlaps the checksum")
+	}
+
+	if _, err := e.writeAt(data, off); err != nil {
+		return err
+	}
+
+	sum, err := e.checksum()
+	if err != nil {
+		return err
+	}
+
+	sb := []byte{uint8(sum >> 8), uint8(sum)}
+	_, err = e.writeAt(sb, e.conf.ChecksumAddr)
+	return err
+}
+
+// VerifyChecksum reports whether the checksum stored at ChecksumAddr
+// matches the data region. The file pointer is not changed.
+func (e *ee24) VerifyChecksum() (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.conf.ChecksumAddr == 0 {
+		return false, errNoChecksum
+	}
+
+	sum, err := e.checksum()
+	if err != nil {
+		return false, err
+	}
+
+	sb := make([]byte, 2)
+	if _, err := e.readAt(sb, e.conf.ChecksumAddr); err != nil {
+		return false, err
+	}
+
+	return uint16(sb[0])<<8|uint16(sb[1]) == sum, nil
+}
+
+// checksum computes the checksum over the data region.
+func (e *ee24) checksum() (uint16, error) {
+	data := make([]byte, e.conf.ChecksumAddr)
+	if _, err := e.readAt(data, 0); err != nil {
+		return 0, err
+	}
+
+	if e.conf.Checksum != nil {
+		return e.conf.Checksum(data), nil
+	}
+	return crc16(data), nil
 }
@@ -5,8 +5,15 @@
 package i2cm
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"io"
+	"strings"
 	"testing"
+	"time"
 )
 
 // log entry for something x 8 bit transfers
@@ -29,6 +36,7 @@ type PVT24 struct {
 	mem      []byte
 	pagesize uint
 	log      []tXx8item
+	probing  bool // a start was sent, the address byte is next
 }
 
 func (p *PVT24) rwhandler(memaddr uint, startpagebase uint, wb, rb []byte) {
@@ -53,7 +61,7 @@ func (p *PVT24) rwhandler(memaddr uint, startpagebase uint, wb, rb []byte) {
 
 func (p *PVT24) Transact8x8(addr Addr, regaddr uint8, wb, rb []byte) (int, int, error) {
 	// read and write logic is intentionally kept simple and different
-	// in style from the eeprom routines. maybe i will make different 
+	// in style from the eeprom routines. maybe i will make different
 	// mistakes both way around :)
 
 	if len(wb) > 0 && len(rb) > 0 {
@@ -72,7 +80,7 @@ func (p *PVT24) Transact8x8(addr Addr, regaddr uint8, wb, rb []byte) (int, int,
 
 func (p *PVT24) Transact16x8(addr Addr, regaddr uint16, wb, rb []byte) (int, int, error) {
 	// read and write logic is intentionally kept simple and different
-	// in style from the eeprom routines. maybe i will make different 
+	// in style from the eeprom routines. maybe i will make different
 	// mistakes both way around :)
 
 	if len(wb) > 0 && len(rb) > 0 {
@@ -89,11 +97,28 @@ func (p *PVT24) Transact16x8(addr Addr, regaddr uint16, wb, rb []byte) (int, int
 	return len(wb), len(rb), nil
 }
 
-// has dummy methods for typing reasons
+// low level access is limited to probes of the device, which are
+// logged as transactions without register address and data
+
+func (p *PVT24) Start() error {
+	p.probing = true
+	return nil
+}
+
+func (p *PVT24) Stop() error {
+	p.probing = false
+	return nil
+}
+
+func (p *PVT24) WriteByte(b byte) error {
+	if !p.probing {
+		panic("not implemented")
+	}
+	p.probing = false
+	p.log = append(p.log, tXx8item{addr: Addr7(b >> 1)})
+	return nil
+}
 
-func (p *PVT24) Start() error                    { panic("not implemented") }
-func (p *PVT24) Stop() error                     { panic("not implemented") }
-func (p *PVT24) WriteByte(b byte) error          { panic("not implemented") }
 func (p *PVT24) ReadByte(ack bool) (byte, error) { panic("not implemented") }
 
 func newPVT24(conf EEPROM24Config, t *testing.T) *PVT24 {
@@ -109,6 +134,17 @@ func newPVT24(conf EEPROM24Config, t *testing.T) *PVT24 {
 	return &p
 }
 
+// newTestEEPROM24 returns an EEPROM24 at address 0x50 on m, failing the
+// test if conf is rejected.
+func newTestEEPROM24(t *testing.T, conf EEPROM24Config, m I2CMaster) EEPROM24 {
+	t.Helper()
+	ee, err := NewEEPROM24(m, Addr7(0x50), conf)
+	if err != nil {
+		t.Fatalf("NewEEPROM24 failed unexpectedly: %v", err)
+	}
+	return ee
+}
+
 func TestEEPROM24EOF(t *testing.T) {
 	conf := Conf_24C02
 	pvt := newPVT24(conf, t)
@@ -180,7 +216,7 @@ func TestEEPROM24EOF(t *testing.T) {
 }
 
 func TestEEPROM24Conf(t *testing.T) {
-	defconf := EEPROM24Config{1, 1, 0}
+	defconf := EEPROM24Config{Size: 1, PageSize: 1}
 	devaddr := Addr7(0xA0 >> 1)
 	tr := newPVT24(defconf, t)
 
@@ -191,9 +227,17 @@ func TestEEPROM24Conf(t *testing.T) {
 		}
 	}
 
+	// empty configuration
+	{
+		conf := EEPROM24Config{}
+		if _, err := NewEEPROM24(tr, devaddr, conf); err == nil {
+			t.Errorf("NewEEPROM24 did not fail on empty configuration %#v", conf)
+		}
+	}
+
 	// pagesize not power of 2
 	{
-		conf := EEPROM24Config{2048, 13, 0}
+		conf := EEPROM24Config{Size: 2048, PageSize: 13}
 		if _, err := NewEEPROM24(tr, devaddr, conf); err == nil {
 			t.Errorf("NewEEPROM24 did not fail on invalid configuration %#v", conf)
 		}
@@ -201,7 +245,7 @@ func TestEEPROM24Conf(t *testing.T) {
 
 	// size not power of 2
 	{
-		conf := EEPROM24Config{100, 16, 0}
+		conf := EEPROM24Config{Size: 100, PageSize: 16}
 		if _, err := NewEEPROM24(tr, devaddr, conf); err == nil {
 			t.Errorf("NewEEPROM24 did not fail on invalid configuration %#v", conf)
 		}
@@ -209,7 +253,7 @@ func TestEEPROM24Conf(t *testing.T) {
 
 	// size and page size not power of 2
 	{
-		conf := EEPROM24Config{100, 13, 0}
+		conf := EEPROM24Config{Size: 100, PageSize: 13}
 		if _, err := NewEEPROM24(tr, devaddr, conf); err == nil {
 			t.Errorf("NewEEPROM24 did not fail on invalid configuration %#v", conf)
 		}
@@ -217,7 +261,7 @@ func TestEEPROM24Conf(t *testing.T) {
 
 	// size too big
 	{
-		conf := EEPROM24Config{2 * MAX_EEPROM_SIZE, 16, 0}
+		conf := EEPROM24Config{Size: 2 * MAX_EEPROM_SIZE, PageSize: 16}
 		if _, err := NewEEPROM24(tr, devaddr, conf); err == nil {
 			t.Errorf("NewEEPROM24 did not fail on invalid (size too big) configuration %#v\n", conf)
 		}
@@ -236,23 +280,23 @@ func TestEEPROM24InOut(t *testing.T) {
 		nexp   int
 		errexp error
 	}{ // small EEPROM configurations
-		{EEPROM24Config{1024, 8, 0}, 6, true, []byte{0x22, 0x23, 0x2c, 0x2d, 0x2e, 0x2f}, 6, nil},
-		{EEPROM24Config{128, 8, 0}, 123, true, []byte{0x5f, 0x58, 0x59, 0x5a, 0x5b, 0x00, 0x00, 0x00, 0x00}, 5, nil}, // double shot EOF returns err==nil on first call
-		{EEPROM24Config{2048, 4, 0}, 9, false, []byte{0x0fe}, 1, nil},                                                // single byte write
-		{EEPROM24Config{2048, 4, 0}, 2040, false, []byte{0xfc, 0xfd, 0xfe, 0xff}, 4, nil},                            // full page
-		{EEPROM24Config{2048, 4, 0}, 513, false, []byte{0x01, 0x02, 0x03, 0x04}, 4, nil},                             // 1 byte in next page
-		{EEPROM24Config{512, 4, 0}, 239, false, []byte{1, 2, 3, 4, 5, 6}, 6, nil},                                    // 1 byte partial, 4 bytes full, 1 byte partial
-		{EEPROM24Config{512, 8, 0}, 254, false, []byte{1, 2, 3}, 3, nil},                                             // span i2c device boundary
-		{EEPROM24Config{1024, 16, 0}, 1022, false, []byte{1, 2, 3, 4}, 2, io.EOF},                                    // test EOF. write employs a single shot EOF strategy
+		{EEPROM24Config{Size: 1024, PageSize: 8}, 6, true, []byte{0x22, 0x23, 0x2c, 0x2d, 0x2e, 0x2f}, 6, nil},
+		{EEPROM24Config{Size: 128, PageSize: 8}, 123, true, []byte{0x5f, 0x58, 0x59, 0x5a, 0x5b, 0x00, 0x00, 0x00, 0x00}, 5, nil}, // double shot EOF returns err==nil on first call
+		{EEPROM24Config{Size: 2048, PageSize: 4}, 9, false, []byte{0x0fe}, 1, nil},                                                // single byte write
+		{EEPROM24Config{Size: 2048, PageSize: 4}, 2040, false, []byte{0xfc, 0xfd, 0xfe, 0xff}, 4, nil},                            // full page
+		{EEPROM24Config{Size: 2048, PageSize: 4}, 513, false, []byte{0x01, 0x02, 0x03, 0x04}, 4, nil},                             // 1 byte in next page
+		{EEPROM24Config{Size: 512, PageSize: 4}, 239, false, []byte{1, 2, 3, 4, 5, 6}, 6, nil},                                    // 1 byte partial, 4 bytes full, 1 byte partial
+		{EEPROM24Config{Size: 512, PageSize: 8}, 254, false, []byte{1, 2, 3}, 3, nil},                                             // span i2c device boundary
+		{EEPROM24Config{Size: 1024, PageSize: 16}, 1022, false, []byte{1, 2, 3, 4}, 2, io.EOF},                                    // test EOF. write employs a single shot EOF strategy
 		// large EEPROM configurations
-		{EEPROM24Config{1 << 16, 32, 0}, 6, true, []byte{0x22, 0x23, 0x2c, 0x2d, 0x2e, 0x2f}, 6, nil},
-		{EEPROM24Config{1 << 16, 8, 0}, (1 << 16) - 5, true, []byte{0xdf, 0xd8, 0xd9, 0xda, 0xdb, 0x00, 0x00, 0x00, 0x00}, 5, nil}, // double shot EOF returns err==nil on first call
-		{EEPROM24Config{1 << 16, 4, 0}, 9, false, []byte{0x0fe}, 1, nil},                                                           // single byte write
-		{EEPROM24Config{1 << 16, 4, 0}, 2040, false, []byte{0xfc, 0xfd, 0xfe, 0xff}, 4, nil},                                       // full page
-		{EEPROM24Config{1 << 16, 4, 0}, 513, false, []byte{0x01, 0x02, 0x03, 0x04}, 4, nil},                                        // 1 byte in next page
-		{EEPROM24Config{1 << 16, 4, 0}, 239, false, []byte{1, 2, 3, 4, 5, 6}, 6, nil},                                              // 1 byte partial, 4 bytes full, 1 byte partial
-		{EEPROM24Config{1 << 16, 8, 0}, 254, false, []byte{1, 2, 3}, 3, nil},                                                       // span i2c device boundary
-		{EEPROM24Config{1 << 16, 16, 0}, (1 << 16) - 2, false, []byte{1, 2, 3, 4}, 2, io.EOF},                                      // test EOF. write employs a single shot EOF strategy
+		{EEPROM24Config{Size: 1 << 16, PageSize: 32}, 6, true, []byte{0x22, 0x23, 0x2c, 0x2d, 0x2e, 0x2f}, 6, nil},
+		{EEPROM24Config{Size: 1 << 16, PageSize: 8}, (1 << 16) - 5, true, []byte{0xdf, 0xd8, 0xd9, 0xda, 0xdb, 0x00, 0x00, 0x00, 0x00}, 5, nil}, // double shot EOF returns err==nil on first call
+		{EEPROM24Config{Size: 1 << 16, PageSize: 4}, 9, false, []byte{0x0fe}, 1, nil},                                                           // single byte write
+		{EEPROM24Config{Size: 1 << 16, PageSize: 4}, 2040, false, []byte{0xfc, 0xfd, 0xfe, 0xff}, 4, nil},                                       // full page
+		{EEPROM24Config{Size: 1 << 16, PageSize: 4}, 513, false, []byte{0x01, 0x02, 0x03, 0x04}, 4, nil},                                        // 1 byte in next page
+		{EEPROM24Config{Size: 1 << 16, PageSize: 4}, 239, false, []byte{1, 2, 3, 4, 5, 6}, 6, nil},                                              // 1 byte partial, 4 bytes full, 1 byte partial
+		{EEPROM24Config{Size: 1 << 16, PageSize: 8}, 254, false, []byte{1, 2, 3}, 3, nil},                                                       // span i2c device boundary
+		{EEPROM24Config{Size: 1 << 16, PageSize: 16}, (1 << 16) - 2, false, []byte{1, 2, 3, 4}, 2, io.EOF},                                      // test EOF. write employs a single shot EOF strategy
 	}
 
 	for i, c := range cases {
@@ -328,3 +372,1385 @@ func TestEEPROM24InOut(t *testing.T) {
 		}
 	}
 }
+
+func TestEEPROM24Ready(t *testing.T) {
+	conf := Conf_24C02
+
+	ee := newTestEEPROM24(t, conf, newmemdev256(Addr7(0x50)))
+	if ready, err := ee.(*ee24).Ready(); !ready || err != nil {
+		t.Errorf("expected ACKing device to be ready, got %v, %v", ready, err)
+	}
+
+	ee = newTestEEPROM24(t, conf, &alwaysNACK{})
+	if ready, err := ee.(*ee24).Ready(); ready || err != nil {
+		t.Errorf("expected NACKing device to be busy without an error, got %v, %v", ready, err)
+	}
+}
+
+// failingPVT24 fails the failAt-th transaction, counting from 1, with
+// a NACK.
+type failingPVT24 struct {
+	*PVT24
+	failAt int
+	n      int
+}
+
+func (f *failingPVT24) Transact8x8(addr Addr, regaddr uint8, wb, rb []byte) (int, int, error) {
+	f.n++
+	if f.n == f.failAt {
+		return 0, 0, NACKReceived
+	}
+	return f.PVT24.Transact8x8(addr, regaddr, wb, rb)
+}
+
+func TestEEPROM24WriteShortCopy(t *testing.T) {
+	conf := Conf_24C02
+	f := &failingPVT24{PVT24: newPVT24(conf, t), failAt: 2}
+	ee := newTestEEPROM24(t, conf, f)
+
+	// hide bytes.Reader's WriterTo so that io.Copy uses Write in a loop
+	src := struct{ io.Reader }{bytes.NewReader(make([]byte, 3*conf.PageSize))}
+	n, err := io.Copy(ee, src)
+	if err != NACKReceived {
+		t.Fatalf("expected io.Copy to fail with NACKReceived, got %v", err)
+	}
+	if n != int64(conf.PageSize) {
+		t.Fatalf("expected io.Copy to have copied the first page of %d bytes, copied %d", conf.PageSize, n)
+	}
+	if p := ee.(*ee24).p; p != conf.PageSize {
+		t.Fatalf("expected file pointer at %d, got %d", conf.PageSize, p)
+	}
+}
+
+func TestEEPROM24LastOpStats(t *testing.T) {
+	conf := Conf_24C02
+	ee := newTestEEPROM24(t, conf, newPVT24(conf, t))
+	_ee := ee.(*ee24)
+
+	ee.Write(make([]byte, 20))
+	if n, dur := _ee.LastOpStats(); n != 20 || dur < 0 {
+		t.Errorf("expected stats for a 20 byte write, got %d bytes in %v", n, dur)
+	}
+
+	ee.Seek(-4, 2)
+	ee.Read(make([]byte, 10))
+	if n, dur := _ee.LastOpStats(); n != 4 || dur < 0 {
+		t.Errorf("expected stats for a 4 byte read, got %d bytes in %v", n, dur)
+	}
+}
+
+func TestEEPROM24Checksum(t *testing.T) {
+	conf := Conf_24C02
+	conf.ChecksumAddr = conf.Size - 2
+	pvt := newPVT24(conf, t)
+	ee := newTestEEPROM24(t, conf, pvt)
+	_ee := ee.(*ee24)
+
+	if ok, err := _ee.VerifyChecksum(); ok || err != nil {
+		t.Fatalf("expected the checksum of the initial pattern not to match, got %v, %v", ok, err)
+	}
+
+	if err := _ee.WriteWithChecksum(0x10, []byte{1, 2, 3}); err != nil {
+		t.Fatalf("WriteWithChecksum failed: %v", err)
+	}
+	if ok, err := _ee.VerifyChecksum(); !ok || err != nil {
+		t.Fatalf("expected checksum to match after WriteWithChecksum, got %v, %v", ok, err)
+	}
+	sum := crc16(pvt.mem[:conf.ChecksumAddr])
+	if pvt.mem[conf.ChecksumAddr] != uint8(sum>>8) || pvt.mem[conf.ChecksumAddr+1] != uint8(sum) {
+		t.Fatalf("checksum not stored big endian at %#02x", conf.ChecksumAddr)
+	}
+
+	// corruption is detected
+	pvt.mem[0x11] ^= 0xff
+	if ok, err := _ee.VerifyChecksum(); ok || err != nil {
+		t.Fatalf("expected checksum mismatch after corruption, got %v, %v", ok, err)
+	}
+
+	if err := _ee.WriteWithChecksum(conf.ChecksumAddr-1, []byte{1, 2}); err == nil {
+		t.Fatalf("WriteWithChecksum accepted data overlapping the checksum")
+	}
+
+	if _ee.p != 0 {
+		t.Fatalf("checksum methods moved the file pointer to %d", _ee.p)
+	}
+}
+
+func TestEEPROM24ReadTransactions(t *testing.T) {
+	conf := EEPROM24Config{Size: 2048, PageSize: 16}
+	pvt := newPVT24(conf, t)
+	ee := newTestEEPROM24(t, conf, pvt)
+
+	// a read within one bank is not split at pages
+	ee.Seek(10, 0)
+	if n, err := ee.Read(make([]byte, 100)); n != 100 || err != nil {
+		t.Fatalf("expected to read 100 bytes, got %d, %v", n, err)
+	}
+	if len(pvt.log) != 1 {
+		t.Fatalf("expected a single transaction, got %d", len(pvt.log))
+	}
+
+	// a read across a bank boundary is split at the boundary
+	pvt.log = nil
+	ee.Seek(200, 0)
+	rb := make([]byte, 100)
+	if n, err := ee.Read(rb); n != 100 || err != nil {
+		t.Fatalf("expected to read 100 bytes, got %d, %v", n, err)
+	}
+	if len(pvt.log) != 2 {
+		t.Fatalf("expected two transactions, got %d", len(pvt.log))
+	}
+	if l := pvt.log[0]; l.addr != Addr7(0x50) || l.regaddr != 200 || l.nr != 56 {
+		t.Errorf("unexpected first transaction %#v", l)
+	}
+	if l := pvt.log[1]; l.addr != Addr7(0x51) || l.regaddr != 0 || l.nr != 44 {
+		t.Errorf("unexpected second transaction %#v", l)
+	}
+	if string(rb) != string(pvt.mem[200:300]) {
+		t.Errorf("read % x, expected % x", rb, pvt.mem[200:300])
+	}
+}
+
+func TestEEPROM24WriteOrder(t *testing.T) {
+	conf := EEPROM24Config{Size: 2048, PageSize: 16, WriteDelay: 5 * time.Millisecond}
+	pvt := newPVT24(conf, t)
+	ee := newTestEEPROM24(t, conf, pvt)
+
+	// log transactions and delays in the order they happen
+	var events []string
+	logged := 0
+	logtx := func() {
+		for _, l := range pvt.log[logged:] {
+			events = append(events, fmt.Sprintf("write %#02x %#02x %d", l.addr.GetBaseAddr(), l.regaddr, l.nw))
+		}
+		logged = len(pvt.log)
+	}
+	orig := sleep
+	sleep = func(d time.Duration) {
+		logtx()
+		events = append(events, fmt.Sprintf("delay %v", d))
+	}
+	t.Cleanup(func() { sleep = orig })
+
+	// the write spans the last page of bank 0x50 and the first two of
+	// bank 0x51
+	ee.Seek(248, 0)
+	if n, err := ee.Write(make([]byte, 40)); n != 40 || err != nil {
+		t.Fatalf("expected to write 40 bytes, got %d, %v", n, err)
+	}
+	logtx()
+
+	exp := []string{
+		"write 0x50 0xf8 8",
+		"delay 5ms",
+		"write 0x51 0x00 16",
+		"delay 5ms",
+		"write 0x51 0x10 16",
+		"delay 5ms",
+	}
+	if strings.Join(events, "\n") != strings.Join(exp, "\n") {
+		t.Fatalf("expected events\n%s\ngot\n%s", strings.Join(exp, "\n"), strings.Join(events, "\n"))
+	}
+}
+
+func TestEEPROM24ReadReverse(t *testing.T) {
+	conf := EEPROM24Config{Size: 2048, PageSize: 16}
+	pvt := newPVT24(conf, t)
+	ee := newTestEEPROM24(t, conf, pvt)
+	_ee := ee.(*ee24)
+
+	reversed := func(b []byte) string {
+		r := make([]byte, len(b))
+		for i := range b {
+			r[len(b)-1-i] = b[i]
+		}
+		return string(r)
+	}
+
+	// across a bank boundary
+	b := make([]byte, 20)
+	if n, err := _ee.ReadReverse(b, 265); n != 20 || err != nil {
+		t.Fatalf("expected to read 20 bytes, got %d, %v", n, err)
+	}
+	if string(b) != reversed(pvt.mem[246:266]) {
+		t.Errorf("read % x, expected the reverse of % x", b, pvt.mem[246:266])
+	}
+	if len(pvt.log) != 2 {
+		t.Errorf("expected two transactions, got %d", len(pvt.log))
+	}
+
+	// at the beginning of the array
+	b = make([]byte, 10)
+	if n, err := _ee.ReadReverse(b, 3); n != 4 || err != io.EOF {
+		t.Fatalf("expected to read 4 bytes and io.EOF, got %d, %v", n, err)
+	}
+	if string(b[:4]) != reversed(pvt.mem[0:4]) {
+		t.Errorf("read % x, expected the reverse of % x", b[:4], pvt.mem[0:4])
+	}
+
+	if _, err := _ee.ReadReverse(b, conf.Size); err == nil {
+		t.Error("ReadReverse accepted a start offset beyond the array")
+	}
+	n := len(pvt.log)
+	if nr, err := _ee.ReadReverse(nil, 10); nr != 0 || err != nil {
+		t.Errorf("expected an empty read to succeed, got %d, %v", nr, err)
+	}
+	if len(pvt.log) != n {
+		t.Errorf("an empty read accessed the bus")
+	}
+	if _ee.p != 0 {
+		t.Errorf("ReadReverse moved the file pointer to %d", _ee.p)
+	}
+}
+
+func TestEEPROM24WriteReadyCheck(t *testing.T) {
+	delays := fakeSleep(t)
+
+	var addrs []Addr
+	conf := EEPROM24Config{Size: 2048, PageSize: 16, WriteDelay: 5 * time.Millisecond}
+	conf.WriteReadyCheck = func(tr Transactor, addr Addr) (bool, error) {
+		addrs = append(addrs, addr)
+		// busy on every other call
+		return len(addrs)%2 == 0, nil
+	}
+	ee := newTestEEPROM24(t, conf, newPVT24(conf, t))
+
+	ee.Seek(248, 0)
+	if n, err := ee.Write(make([]byte, 16)); n != 16 || err != nil {
+		t.Fatalf("expected to write 16 bytes, got %d, %v", n, err)
+	}
+	exp := []Addr{Addr7(0x50), Addr7(0x50), Addr7(0x51), Addr7(0x51)}
+	if fmt.Sprint(addrs) != fmt.Sprint(exp) {
+		t.Fatalf("expected checks for %v, got %v", exp, addrs)
+	}
+	for _, d := range *delays {
+		if d == conf.WriteDelay {
+			t.Fatalf("WriteDelay waited for although WriteReadyCheck is set")
+		}
+	}
+
+	// errors of the check fail the write
+	errcheck := errors.New("status register unreadable")
+	conf.WriteReadyCheck = func(Transactor, Addr) (bool, error) { return false, errcheck }
+	ee, _ = NewEEPROM24(newPVT24(conf, t), Addr7(0xa0>>1), conf)
+	if n, err := ee.Write(make([]byte, 20)); n != 16 || err != errcheck {
+		t.Fatalf("expected the first page written and the check's error, got %d, %v", n, err)
+	}
+
+	// a device which never gets ready times out
+	conf.WriteReadyCheck = func(Transactor, Addr) (bool, error) { return false, nil }
+	conf.WriteTimeout = time.Millisecond
+	ee, _ = NewEEPROM24(newPVT24(conf, t), Addr7(0xa0>>1), conf)
+	if _, err := ee.Write(make([]byte, 1)); err == nil {
+		t.Fatalf("expected the write to time out")
+	}
+}
+
+func TestPollACK(t *testing.T) {
+	tr := NewTransactor(newmemdev256(Addr7(0x50)))
+	if ready, err := PollACK(tr, Addr7(0x50)); !ready || err != nil {
+		t.Errorf("expected ACKing device to be ready, got %v, %v", ready, err)
+	}
+	if ready, err := PollACK(NewTransactor(&alwaysNACK{}), Addr7(0x50)); ready || err != nil {
+		t.Errorf("expected NACKing device to be busy without an error, got %v, %v", ready, err)
+	}
+
+	// with access to the master, the device is probed without
+	// changing its address pointer
+	m := NewRecordingMaster(newmemdev256(Addr7(0x50)))
+	if ready, err := PollACK(masterTransactor{NewTransactor(m), m}, Addr7(0x50)); !ready || err != nil {
+		t.Errorf("expected probed device to be ready, got %v, %v", ready, err)
+	}
+	checkLog(t, recorded(m), []i2cItem{{t_START, 0, false, nil},
+		{t_WRITE, 0xa0, false, nil},
+		{t_STOP, 0, false, nil},
+	})
+	n := &alwaysNACK{}
+	if ready, err := PollACK(masterTransactor{NewTransactor(n), n}, Addr7(0x50)); ready || err != nil {
+		t.Errorf("expected probed NACKing device to be busy without an error, got %v, %v", ready, err)
+	}
+}
+
+func TestEEPROM24ConfigAddressMap(t *testing.T) {
+	cases := []struct {
+		conf EEPROM24Config
+		exp  []BankMapping
+	}{
+		{Conf_24C02, []BankMapping{{0x50, [2]uint{0, 256}}}},
+		{EEPROM24Config{Size: 128, PageSize: 8}, []BankMapping{{0x50, [2]uint{0, 128}}}},
+		{EEPROM24Config{Size: 2048, PageSize: 16}, []BankMapping{
+			{0x50, [2]uint{0, 256}},
+			{0x51, [2]uint{256, 512}},
+			{0x52, [2]uint{512, 768}},
+			{0x53, [2]uint{768, 1024}},
+			{0x54, [2]uint{1024, 1280}},
+			{0x55, [2]uint{1280, 1536}},
+			{0x56, [2]uint{1536, 1792}},
+			{0x57, [2]uint{1792, 2048}},
+		}},
+		{Conf_24C128, []BankMapping{{0x50, [2]uint{0, 16384}}}},
+		{EEPROM24Config{Size: 1 << 17, PageSize: 256}, []BankMapping{
+			{0x50, [2]uint{0, 1 << 16}},
+			{0x51, [2]uint{1 << 16, 1 << 17}},
+		}},
+	}
+
+	for i, c := range cases {
+		m := c.conf.AddressMap(Addr7(0x50))
+		if fmt.Sprint(m) != fmt.Sprint(c.exp) {
+			t.Errorf("case %d: expected %v, got %v", i, c.exp, m)
+		}
+	}
+}
+
+func TestEEPROM24ReadOnly(t *testing.T) {
+	conf := Conf_24C02
+	conf.ReadOnly = true
+	conf.ChecksumAddr = 0x80
+	pvt := newPVT24(conf, t)
+	ee := newTestEEPROM24(t, conf, pvt)
+
+	ee.Seek(0x10, 0)
+	if n, err := ee.Write([]byte{1, 2, 3}); n != 0 || err != ErrReadOnly {
+		t.Fatalf("expected (0, ErrReadOnly), got (%d, %v)", n, err)
+	}
+	if err := ee.(*ee24).WriteWithChecksum(0, []byte{1}); err != ErrReadOnly {
+		t.Fatalf("expected WriteWithChecksum to fail with ErrReadOnly, got %v", err)
+	}
+	if len(pvt.log) != 0 {
+		t.Fatalf("expected no bus activity, got %d transactions", len(pvt.log))
+	}
+
+	b := make([]byte, 4)
+	if n, err := ee.Read(b); n != 4 || err != nil {
+		t.Fatalf("expected to read 4 bytes, got %d, %v", n, err)
+	}
+	if string(b) != string(pvt.mem[0x10:0x14]) {
+		t.Fatalf("read % x, expected % x", b, pvt.mem[0x10:0x14])
+	}
+}
+
+func TestEEPROM24OptionalInterfaces(t *testing.T) {
+	conf := EEPROM24Config{Size: 2048, PageSize: 16}
+	pvt := newPVT24(conf, t)
+	ee := newTestEEPROM24(t, conf, pvt)
+
+	s, ok := ee.(Sizer)
+	if !ok {
+		t.Fatalf("EEPROM24 is not a Sizer")
+	}
+	if size := s.Size(); size != int64(conf.Size) {
+		t.Errorf("expected Size %d, got %d", conf.Size, size)
+	}
+	if size, err := eepromSize(struct{ EEPROM24 }{ee}); size != int64(conf.Size) || err != nil {
+		t.Errorf("expected the size determined by seeking to be %d, got %d, %v", conf.Size, size, err)
+	}
+
+	ee.Seek(0x10, 0)
+	ee.Write([]byte{1, 2, 3})
+	n := len(pvt.log)
+
+	sy, ok := ee.(Syncer)
+	if !ok {
+		t.Fatalf("EEPROM24 is not a Syncer")
+	}
+	if err := sy.Sync(); err != nil {
+		t.Errorf("Sync failed: %v", err)
+	}
+	if err := ee.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	if len(pvt.log) != n {
+		t.Errorf("Sync and Close accessed the bus of an unbuffered EEPROM24")
+	}
+	if string(pvt.mem[0x10:0x13]) != "\x01\x02\x03" {
+		t.Errorf("data written is not in the device: % x", pvt.mem[0x10:0x13])
+	}
+}
+
+func TestEEPROM24Sync(t *testing.T) {
+	md := newmemdev256(Addr7(0x50))
+	m := NewRecordingMaster(md)
+	ee := newTestEEPROM24(t, EEPROM24Config{Size: 256, PageSize: 8}, m)
+	if _, err := ee.Write([]byte{1, 2, 3}); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	m.Reset()
+
+	sy, ok := ee.(Syncer)
+	if !ok {
+		t.Fatalf("expected %T to be a Syncer", ee)
+	}
+	if err := sy.Sync(); err != nil {
+		t.Errorf("Sync failed: %v", err)
+	}
+	if log := recorded(m); len(log) != 0 {
+		t.Errorf("Sync accessed the bus: %v", log)
+	}
+	if string(md.mem[:3]) != "\x01\x02\x03" {
+		t.Errorf("data written is not in the device: % x", md.mem[:3])
+	}
+}
+
+func TestEEPROM24Close(t *testing.T) {
+	md := newmemdev256(Addr7(0x50))
+	copy(md.mem[:], []byte{1, 2, 3})
+	m := NewRecordingMaster(md)
+	ee := newTestEEPROM24(t, EEPROM24Config{Size: 256, PageSize: 8}, m)
+
+	closers := map[string]func() error{
+		"ee24": ee.Close,
+		"lockedEE24": func() error {
+			return ee.(*ee24).Locked(func(e EEPROM24) error { return e.Close() })
+		},
+	}
+	for name, close := range closers {
+		m.Reset()
+		if err := close(); err != nil {
+			t.Errorf("%s: Close failed: %v", name, err)
+		}
+		if log := recorded(m); len(log) != 0 {
+			t.Errorf("%s: Close accessed the bus: %v", name, log)
+		}
+
+		// the master is left open
+		b := make([]byte, 3)
+		if _, n, err := NewTransactor(m).Transact8x8(Addr7(0x50), 0, nil, b); n != 3 || err != nil || string(b) != "\x01\x02\x03" {
+			t.Errorf("%s: expected to read 01 02 03 after Close, got % x, %v", name, b[:n], err)
+		}
+	}
+}
+
+func TestEEPROM24WriteWith(t *testing.T) {
+	delays := fakeSleep(t)
+
+	conf := EEPROM24Config{Size: 256, PageSize: 8, WriteDelay: 5 * time.Millisecond}
+	pvt := newPVT24(conf, t)
+	ee := newTestEEPROM24(t, conf, pvt)
+	_ee := ee.(*ee24)
+
+	// two pages each
+	cases := []struct {
+		mode WriteMode
+		exp  string
+	}{
+		{WriteMode{}, "[5ms 5ms]"},
+		{WriteModeDelay(time.Millisecond), "[1ms 1ms]"},
+		{WriteModeDelay(0), "[]"},
+	}
+	for i, c := range cases {
+		*delays = nil
+		if n, err := _ee.WriteWith(make([]byte, 16), c.mode); n != 16 || err != nil {
+			t.Fatalf("case %d: expected to write 16 bytes, got %d, %v", i, n, err)
+		}
+		if fmt.Sprint(*delays) != c.exp {
+			t.Errorf("case %d: expected delays %s, got %v", i, c.exp, *delays)
+		}
+	}
+	if _ee.p != 48 {
+		t.Errorf("expected file pointer at 48, got %d", _ee.p)
+	}
+
+	// polling addresses the device after each page
+	*delays = nil
+	pvt.log = nil
+	if n, err := _ee.WriteWith(make([]byte, 16), WriteModePollACK); n != 16 || err != nil {
+		t.Fatalf("expected to write 16 bytes, got %d, %v", n, err)
+	}
+	if len(*delays) != 0 || len(pvt.log) != 4 {
+		t.Errorf("expected 2 page writes and 2 polls without delays, got %d transactions and delays %v", len(pvt.log), *delays)
+	}
+
+	if _, err := _ee.WriteWith(make([]byte, 1), WriteModeDelay(-1)); err == nil {
+		t.Errorf("WriteWith accepted a negative delay")
+	}
+}
+
+func TestSmallAddrReadPlan(t *testing.T) {
+	cases := []struct {
+		start, n uint
+		exp      []SmallAddrRead
+	}{
+		{0, 0, nil},
+		{0, 256, []SmallAddrRead{{0x50, 0x00, 256}}},
+		{0x10, 0x20, []SmallAddrRead{{0x50, 0x10, 0x20}}},
+		{0xff, 2, []SmallAddrRead{{0x50, 0xff, 1}, {0x51, 0x00, 1}}},
+		{0x180, 0x300, []SmallAddrRead{
+			{0x51, 0x80, 0x80},
+			{0x52, 0x00, 0x100},
+			{0x53, 0x00, 0x100},
+			{0x54, 0x00, 0x80},
+		}},
+		{0x700, 0x100, []SmallAddrRead{{0x57, 0x00, 0x100}}},
+	}
+
+	for i, c := range cases {
+		plan := SmallAddrReadPlan(c.start, c.n, Addr7(0x50))
+		if fmt.Sprint(plan) != fmt.Sprint(c.exp) {
+			t.Errorf("case %d: expected %v, got %v", i, c.exp, plan)
+		}
+	}
+
+	// exhaustively check that plans cover the range once, in order
+	for start := uint(0); start < 2048; start += 7 {
+		for _, n := range []uint{1, 255, 256, 257, 600} {
+			pos := start
+			for _, st := range SmallAddrReadPlan(start, n, Addr7(0x50)) {
+				if st.Dev != Addr7(0x50+pos>>8) || st.Reg != uint8(pos) || st.Len == 0 || uint(st.Reg)+st.Len > 256 {
+					t.Fatalf("start %#x, n %d: invalid transaction %v at position %#x", start, n, st, pos)
+				}
+				pos += st.Len
+			}
+			if pos != start+n {
+				t.Fatalf("start %#x, n %d: plan ends at %#x", start, n, pos)
+			}
+		}
+	}
+}
+
+func TestEEPROM24SkipUnchanged(t *testing.T) {
+	delays := fakeSleep(t)
+
+	conf := Conf_24C02
+	conf.SkipUnchanged = true
+	pvt := newPVT24(conf, t)
+	ee := newTestEEPROM24(t, conf, pvt)
+
+	// three pages of which only the middle one changes
+	b := make([]byte, 3*conf.PageSize)
+	copy(b, pvt.mem[0x10:])
+	b[conf.PageSize+1] ^= 0xff
+	ee.Seek(0x10, 0)
+	if n, err := ee.Write(b); n != len(b) || err != nil {
+		t.Fatalf("expected to write %d bytes, got %d, %v", len(b), n, err)
+	}
+	if string(pvt.mem[0x10:0x10+len(b)]) != string(b) {
+		t.Fatalf("device does not hold the data written")
+	}
+
+	var writes []tXx8item
+	for _, l := range pvt.log {
+		if l.nw > 0 {
+			writes = append(writes, l)
+		}
+	}
+	if len(writes) != 1 || writes[0].regaddr != 0x10+uint16(conf.PageSize) {
+		t.Fatalf("expected a single write of the second page, got %v", writes)
+	}
+	if len(*delays) != 1 {
+		t.Fatalf("expected a single write delay, got %v", *delays)
+	}
+}
+
+func TestEEPROM24ApplyPatch(t *testing.T) {
+	conf := EEPROM24Config{Size: 256, PageSize: 16}
+	pvt := newPVT24(conf, t)
+	ee := newTestEEPROM24(t, conf, pvt)
+	_ee := ee.(*ee24)
+
+	// the image ends within the fourth page, which changes like the
+	// second one
+	img := make([]byte, 3*conf.PageSize+5)
+	copy(img, pvt.mem)
+	img[conf.PageSize] ^= 0xff
+	img[3*conf.PageSize+4] ^= 0xff
+	tail := string(pvt.mem[len(img):])
+
+	pvt.log = nil
+	n, err := _ee.ApplyPatch(img)
+	if n != 2 || err != nil {
+		t.Fatalf("expected 2 pages written, got %d, %v", n, err)
+	}
+	if string(pvt.mem[:len(img)]) != string(img) {
+		t.Fatalf("device does not hold the image")
+	}
+	if string(pvt.mem[len(img):]) != tail {
+		t.Fatalf("ApplyPatch modified the array beyond the image")
+	}
+	// one read and two writes
+	if len(pvt.log) != 3 {
+		t.Fatalf("expected 3 transactions, got %d", len(pvt.log))
+	}
+
+	if n, err := _ee.ApplyPatch(img); n != 0 || err != nil {
+		t.Fatalf("expected no pages written for an unchanged image, got %d, %v", n, err)
+	}
+	if _, err := _ee.ApplyPatch(make([]byte, conf.Size+1)); err == nil {
+		t.Fatalf("ApplyPatch accepted an image larger than the array")
+	}
+}
+
+func TestEEPROM24WriteContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conf := EEPROM24Config{Size: 256, PageSize: 8, WriteTimeout: time.Hour}
+	checks := 0
+	conf.WriteReadyCheck = func(Transactor, Addr) (bool, error) {
+		checks++
+		if checks == 3 {
+			cancel()
+		}
+		return false, nil
+	}
+	ee := newTestEEPROM24(t, conf, newPVT24(conf, t))
+
+	n, err := ee.(*ee24).WriteContext(ctx, make([]byte, 16))
+	if n != 8 || err != context.Canceled {
+		t.Fatalf("expected the first page written and context.Canceled, got %d, %v", n, err)
+	}
+	if checks != 3 {
+		t.Fatalf("expected polling to stop after the cancellation, got %d checks", checks)
+	}
+}
+
+func TestEEPROM24ConfigAddressMode(t *testing.T) {
+	cases := []struct {
+		size  uint
+		small bool
+	}{
+		{128, true},
+		{256, true},
+		{1024, true},
+		{2048, true},  // 24C16
+		{4096, false}, // 24C32
+		{65536, false},
+	}
+	for _, c := range cases {
+		conf := EEPROM24Config{Size: c.size, PageSize: 8}
+		if small := conf.hasSmallAddresses(); small != c.small {
+			t.Errorf("size %d: expected small addresses %v, got %v", c.size, c.small, small)
+		}
+	}
+}
+
+func TestEEPROM24TellRemaining(t *testing.T) {
+	conf := EEPROM24Config{Size: 256, PageSize: 8}
+	ee := newTestEEPROM24(t, conf, newPVT24(conf, t))
+	_ee := ee.(*ee24)
+
+	check := func(what string, pos int64) {
+		if p := _ee.Tell(); p != pos {
+			t.Errorf("after %s: expected Tell %d, got %d", what, pos, p)
+		}
+		if p, err := ee.Seek(0, 1); p != pos || err != nil {
+			t.Errorf("after %s: expected Seek(0, 1) to agree with Tell, got %d, %v", what, p, err)
+		}
+		if r := _ee.Remaining(); r != 256-pos {
+			t.Errorf("after %s: expected Remaining %d, got %d", what, 256-pos, r)
+		}
+	}
+
+	check("open", 0)
+	ee.Write(make([]byte, 10))
+	check("write", 10)
+	ee.Read(make([]byte, 5))
+	check("read", 15)
+	ee.Seek(-6, 2)
+	check("seek", 250)
+	ee.Write(make([]byte, 10))
+	check("write to the end", 256)
+}
+
+func TestEEPROM24Checkpoint(t *testing.T) {
+	conf := EEPROM24Config{Size: 256, PageSize: 8}
+	ee := newTestEEPROM24(t, conf, newPVT24(conf, t))
+	_ee := ee.(*ee24)
+
+	ee.Seek(10, 0)
+	outer := _ee.Checkpoint()
+	ee.Read(make([]byte, 20))
+
+	inner := _ee.Checkpoint()
+	ee.Seek(-1, 2)
+	ee.Write([]byte{0xff})
+
+	inner()
+	if p := _ee.Tell(); p != 30 {
+		t.Fatalf("inner checkpoint restored %d, expected 30", p)
+	}
+
+	ee.Seek(0, 0)
+	outer()
+	if p := _ee.Tell(); p != 10 {
+		t.Fatalf("outer checkpoint restored %d, expected 10", p)
+	}
+
+	// restoring twice is harmless
+	ee.Seek(100, 0)
+	inner()
+	if p := _ee.Tell(); p != 30 {
+		t.Fatalf("inner checkpoint restored %d the second time, expected 30", p)
+	}
+}
+
+func TestEEPROM24PlanWrite(t *testing.T) {
+	fakeSleep(t)
+
+	for _, conf := range []EEPROM24Config{
+		{Size: 2048, PageSize: 16, WriteDelay: 5 * time.Millisecond},
+		{Size: 1 << 17, PageSize: 64, WriteDelay: 5 * time.Millisecond},
+	} {
+		pvt := newPVT24(conf, t)
+		ee := newTestEEPROM24(t, conf, pvt)
+		_ee := ee.(*ee24)
+
+		// across a bank boundary
+		off := conf.bankSize() - 20
+		data := make([]byte, 50)
+		fillPattern(data, 0x42)
+
+		plan, err := _ee.PlanWrite(off, data)
+		if err != nil {
+			t.Fatalf("size %d: PlanWrite failed: %v", conf.Size, err)
+		}
+		if len(pvt.log) != 0 {
+			t.Fatalf("size %d: PlanWrite accessed the device", conf.Size)
+		}
+		if plan.Duration != time.Duration(len(plan.Writes))*conf.WriteDelay {
+			t.Fatalf("size %d: expected a duration of %d write delays, got %v", conf.Size, len(plan.Writes), plan.Duration)
+		}
+
+		// the plan matches what Write does
+		ee.Seek(int64(off), 0)
+		if n, err := ee.Write(data); n != len(data) || err != nil {
+			t.Fatalf("size %d: expected to write %d bytes, got %d, %v", conf.Size, len(data), n, err)
+		}
+		if len(plan.Writes) != len(pvt.log) {
+			t.Fatalf("size %d: planned %d writes, got %d", conf.Size, len(plan.Writes), len(pvt.log))
+		}
+		pos := off
+		for i, pw := range plan.Writes {
+			l := pvt.log[i]
+			if pw.Off != pos || pw.Dev != l.addr || pw.Reg != l.regaddr || int(pw.Len) != l.nw {
+				t.Fatalf("size %d: planned %v at position %#x, got %+v", conf.Size, pw, pos, l)
+			}
+			pos += pw.Len
+		}
+		if pos != off+uint(len(data)) {
+			t.Fatalf("size %d: plan ends at %#x", conf.Size, pos)
+		}
+		if plan.Writes[len(plan.Writes)-1].Dev != Addr7(0x51) {
+			t.Fatalf("size %d: expected the last write to go to the second bank", conf.Size)
+		}
+
+		// writes past the end of the array are rejected
+		if _, err := _ee.PlanWrite(conf.Size-2, data[:3]); err == nil {
+			t.Fatalf("size %d: PlanWrite accepted a write past the end", conf.Size)
+		}
+		if plan, err := _ee.PlanWrite(conf.Size-2, data[:2]); err != nil || len(plan.Writes) != 1 {
+			t.Fatalf("size %d: expected a single write at the end, got %v, %v", conf.Size, plan, err)
+		}
+	}
+}
+
+func TestEEPROM24ReadUntilByte(t *testing.T) {
+	conf := EEPROM24Config{Size: 256, PageSize: 8}
+	pvt := newPVT24(conf, t)
+	ee := newTestEEPROM24(t, conf, pvt)
+	_ee := ee.(*ee24)
+
+	for i := range pvt.mem {
+		pvt.mem[i] = 'x'
+	}
+	pvt.mem[5] = 0   // in the first chunk
+	pvt.mem[45] = 0  // in the second chunk
+	pvt.mem[200] = 0 // beyond maxLen
+
+	rec, err := _ee.ReadUntilByte(0, 100)
+	if len(rec) != 6 || err != nil || _ee.Tell() != 6 {
+		t.Fatalf("expected 6 bytes up to the delimiter, got %d, %v at %d", len(rec), err, _ee.Tell())
+	}
+
+	// the delimiter is found in the second chunk read
+	pvt.log = nil
+	rec, err = _ee.ReadUntilByte(0, 100)
+	if len(rec) != 40 || rec[39] != 0 || err != nil || _ee.Tell() != 46 {
+		t.Fatalf("expected 40 bytes up to the delimiter, got %d, %v at %d", len(rec), err, _ee.Tell())
+	}
+	if len(pvt.log) != 2 {
+		t.Fatalf("expected 2 chunked reads, got %d", len(pvt.log))
+	}
+
+	// maxLen ends the read without an error
+	rec, err = _ee.ReadUntilByte(0, 50)
+	if len(rec) != 50 || err != nil || _ee.Tell() != 96 {
+		t.Fatalf("expected 50 bytes, got %d, %v at %d", len(rec), err, _ee.Tell())
+	}
+
+	// the end of the array ends the read with io.EOF
+	ee.Seek(201, 0)
+	rec, err = _ee.ReadUntilByte(0, 100)
+	if len(rec) != 55 || err != io.EOF || _ee.Tell() != 256 {
+		t.Fatalf("expected 55 bytes and io.EOF, got %d, %v at %d", len(rec), err, _ee.Tell())
+	}
+	if rec, err = _ee.ReadUntilByte(0, 100); len(rec) != 0 || err != io.EOF {
+		t.Fatalf("expected io.EOF at the end, got %d bytes, %v", len(rec), err)
+	}
+}
+
+func TestEEPROM24Words16(t *testing.T) {
+	fakeSleep(t)
+
+	conf := EEPROM24Config{Size: 256, PageSize: 8}
+	pvt := newPVT24(conf, t)
+	ee := newTestEEPROM24(t, conf, pvt)
+	_ee := ee.(*ee24)
+
+	// the first word straddles the boundary of the first page
+	words := []uint16{0x1234, 0x5678, 0x9abc}
+	if n, err := _ee.WriteWords16(7, words, binary.BigEndian); n != 3 || err != nil {
+		t.Fatalf("expected to write 3 words, got %d, %v", n, err)
+	}
+	if string(pvt.mem[7:13]) != "\x12\x34\x56\x78\x9a\xbc" {
+		t.Fatalf("unexpected big endian memory contents % x", pvt.mem[7:13])
+	}
+	if n, err := _ee.WriteWords16(7, words, binary.LittleEndian); n != 3 || err != nil {
+		t.Fatalf("expected to write 3 words, got %d, %v", n, err)
+	}
+	if string(pvt.mem[7:13]) != "\x34\x12\x78\x56\xbc\x9a" {
+		t.Fatalf("unexpected little endian memory contents % x", pvt.mem[7:13])
+	}
+
+	r := make([]uint16, 3)
+	if n, err := _ee.ReadWords16(7, r, binary.LittleEndian); n != 3 || err != nil {
+		t.Fatalf("expected to read 3 words, got %d, %v", n, err)
+	}
+	if fmt.Sprint(r) != fmt.Sprint(words) {
+		t.Fatalf("read %x, expected %x", r, words)
+	}
+
+	// only complete words count at the end of the array
+	if n, err := _ee.WriteWords16(253, words, binary.BigEndian); n != 1 || err != io.EOF {
+		t.Fatalf("expected to write 1 word and io.EOF, got %d, %v", n, err)
+	}
+	if n, err := _ee.ReadWords16(253, r, binary.BigEndian); n != 1 || err != io.EOF || r[0] != 0x1234 {
+		t.Fatalf("expected to read 1 word and io.EOF, got %d, %v, %x", n, err, r[0])
+	}
+	if _ee.Tell() != 0 {
+		t.Fatalf("the file pointer moved to %d", _ee.Tell())
+	}
+}
+
+func TestEEPROM24ReadCurrentAddress(t *testing.T) {
+	md := newmemdev256(Addr7(0x50))
+	fillPattern(md.mem[:], 0x5a)
+	m := NewRecordingMaster(md)
+	ee := newTestEEPROM24(t, EEPROM24Config{Size: 256, PageSize: 8}, m)
+	_ee := ee.(*ee24)
+
+	ee.Seek(0x40, 0)
+	r := make([]byte, 4)
+	if _, err := io.ReadFull(ee, r); err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+
+	// continues where the read left off without an address write
+	m.Reset()
+	if n, err := _ee.ReadCurrentAddress(r); n != 4 || err != nil {
+		t.Fatalf("expected to read 4 bytes, got %d, %v", n, err)
+	}
+	if string(r) != string(md.mem[0x44:0x48]) {
+		t.Fatalf("read % x, expected % x", r, md.mem[0x44:0x48])
+	}
+	if log := recorded(m); len(log) != 7 || log[1] != (i2cItem{t_WRITE, 0xa1, false, nil}) {
+		t.Fatalf("expected a current address read, got %v", log)
+	}
+	if _ee.Tell() != 0x48 {
+		t.Fatalf("expected the file pointer at 0x48, got %#x", _ee.Tell())
+	}
+
+	// reads end at the end of the array
+	ee.Seek(-2, 2)
+	if _, err := io.ReadFull(ee, r[:1]); err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if n, err := _ee.ReadCurrentAddress(r); n != 1 || err != nil || r[0] != md.mem[255] {
+		t.Fatalf("expected to read the last byte, got %d, %v", n, err)
+	}
+	if n, err := _ee.ReadCurrentAddress(r); n != 0 || err != io.EOF {
+		t.Fatalf("expected io.EOF at the end, got %d, %v", n, err)
+	}
+}
+
+func TestEEPROM24Rollover(t *testing.T) {
+	conf := EEPROM24Config{Size: 256, PageSize: 8, Rollover: true}
+	pvt := newPVT24(conf, t)
+	ee := newTestEEPROM24(t, conf, pvt)
+
+	ee.Seek(-3, 2)
+	r := make([]byte, 6)
+	if n, err := ee.Read(r); n != 6 || err != nil {
+		t.Fatalf("expected to read 6 bytes, got %d, %v", n, err)
+	}
+	exp := append(append([]byte{}, pvt.mem[253:]...), pvt.mem[:3]...)
+	if string(r) != string(exp) {
+		t.Fatalf("read % x, expected % x", r, exp)
+	}
+	if p := ee.Tell(); p != 3 {
+		t.Fatalf("expected the file pointer at 3, got %d", p)
+	}
+
+	// a buffer larger than the array wraps several times
+	r = make([]byte, 600)
+	if n, err := ee.Read(r); n != 600 || err != nil {
+		t.Fatalf("expected to read 600 bytes, got %d, %v", n, err)
+	}
+	for i, b := range r {
+		if b != pvt.mem[(3+i)%256] {
+			t.Fatalf("byte %d: expected %#x, got %#x", i, pvt.mem[(3+i)%256], b)
+		}
+	}
+	if p := ee.Tell(); p != (3+600)%256 {
+		t.Fatalf("expected the file pointer at %d, got %d", (3+600)%256, p)
+	}
+
+	// writes still end at the end of the array
+	ee.Seek(-1, 2)
+	if n, err := ee.Write([]byte{1, 2}); n != 1 || err != io.EOF {
+		t.Fatalf("expected to write 1 byte and io.EOF, got %d, %v", n, err)
+	}
+}
+
+func TestEEPROM24IncrementCounter(t *testing.T) {
+	conf := EEPROM24Config{Size: 256, PageSize: 8}
+	pvt := newPVT24(conf, t)
+	ee := newTestEEPROM24(t, conf, pvt)
+	_ee := ee.(*ee24)
+
+	// carry across bytes, big endian counter straddling a page boundary
+	copy(pvt.mem[6:], []byte{0x00, 0x01, 0xff, 0xff})
+	if v, err := _ee.IncrementCounter(6, 4, binary.BigEndian); v != 0x20000 || err != nil {
+		t.Fatalf("expected 0x20000, got %#x, %v", v, err)
+	}
+	if string(pvt.mem[6:10]) != "\x00\x02\x00\x00" {
+		t.Fatalf("unexpected memory contents % x", pvt.mem[6:10])
+	}
+
+	// little endian, odd width
+	copy(pvt.mem[0x20:], []byte{0xff, 0x12, 0x00})
+	if v, err := _ee.IncrementCounter(0x20, 3, binary.LittleEndian); v != 0x1300 || err != nil {
+		t.Fatalf("expected 0x1300, got %#x, %v", v, err)
+	}
+	if string(pvt.mem[0x20:0x23]) != "\x00\x13\x00" {
+		t.Fatalf("unexpected memory contents % x", pvt.mem[0x20:0x23])
+	}
+
+	// overflow leaves the counter alone
+	copy(pvt.mem[0x30:], []byte{0xff, 0xff})
+	pvt.log = nil
+	if _, err := _ee.IncrementCounter(0x30, 2, binary.BigEndian); err == nil {
+		t.Fatalf("overflow not reported")
+	}
+	if len(pvt.log) != 1 || pvt.mem[0x30] != 0xff || pvt.mem[0x31] != 0xff {
+		t.Fatalf("overflowing counter was written")
+	}
+
+	if _, err := _ee.IncrementCounter(0, 9, binary.BigEndian); err == nil {
+		t.Fatalf("invalid width accepted")
+	}
+	if _, err := _ee.IncrementCounter(255, 2, binary.BigEndian); err == nil {
+		t.Fatalf("counter past the end accepted")
+	}
+}
+
+func TestEEPROM24Log(t *testing.T) {
+	var log []string
+	conf := EEPROM24Config{Size: 2048, PageSize: 16}
+	conf.Log = func(format string, args ...interface{}) { log = append(log, fmt.Sprintf(format, args...)) }
+	ee := newTestEEPROM24(t, conf, newPVT24(conf, t))
+
+	ee.Seek(0x1fc, 0)
+	ee.Write(make([]byte, 8))
+	ee.Seek(0xfe, 0)
+	ee.Read(make([]byte, 4))
+
+	exp := []string{
+		"EEPROM24: seek from 0x0 to 0x1fc",
+		"EEPROM24: write 4 bytes at 0x1fc: device Addr7(0x51), register 0xfc, page 0x1f0",
+		"EEPROM24: write 4 bytes at 0x200: device Addr7(0x52), register 0x00, page 0x200",
+		"EEPROM24: seek from 0x204 to 0xfe",
+		"EEPROM24: read 2 bytes at 0xfe: device Addr7(0x50), register 0xfe",
+		"EEPROM24: read 2 bytes at 0x100: device Addr7(0x51), register 0x00",
+	}
+	if strings.Join(log, "\n") != strings.Join(exp, "\n") {
+		t.Fatalf("expected log\n%s\ngot\n%s", strings.Join(exp, "\n"), strings.Join(log, "\n"))
+	}
+}
+
+func TestEEPROM24PhysicalAddr(t *testing.T) {
+	for _, conf := range []EEPROM24Config{
+		{Size: 256, PageSize: 8},
+		{Size: 2048, PageSize: 16},
+		{Size: 1 << 17, PageSize: 64},
+	} {
+		pvt := newPVT24(conf, t)
+		ee := newTestEEPROM24(t, conf, pvt)
+		_ee := ee.(*ee24)
+
+		// every position maps to where the driver reads it
+		step := uint(1)
+		if conf.Size > 2048 {
+			step = 97
+		}
+		for off := uint(0); off < conf.Size; off += step {
+			dev, reg, err := _ee.PhysicalAddr(off)
+			if err != nil {
+				t.Fatalf("size %d, position %#x: %v", conf.Size, off, err)
+			}
+
+			pvt.log = nil
+			ee.Seek(int64(off), 0)
+			ee.Read(make([]byte, 1))
+			if l := pvt.log[0]; l.addr != dev || l.regaddr != reg {
+				t.Fatalf("size %d, position %#x: PhysicalAddr returned %v/%#x, the driver used %v/%#x", conf.Size, off, dev, reg, l.addr, l.regaddr)
+			}
+		}
+
+		if _, _, err := _ee.PhysicalAddr(conf.Size); err == nil {
+			t.Fatalf("size %d: position at the end accepted", conf.Size)
+		}
+	}
+
+	ee, _ := NewFakeEEPROM24(EEPROM24Config{Size: 1 << 17, PageSize: 64})
+	for _, c := range []struct {
+		off uint
+		dev Addr7
+		reg uint16
+	}{{0, 0x50, 0}, {0xffff, 0x50, 0xffff}, {0x10000, 0x51, 0}, {0x1abcd, 0x51, 0xabcd}} {
+		if dev, reg, _ := ee.(*ee24).PhysicalAddr(c.off); dev != c.dev || reg != c.reg {
+			t.Errorf("position %#x: expected %v/%#x, got %v/%#x", c.off, c.dev, c.reg, dev, reg)
+		}
+	}
+}
+
+func TestLooksLikeFloatingRead(t *testing.T) {
+	cases := []struct {
+		b   []byte
+		exp bool
+	}{
+		{bytes.Repeat([]byte{0xff}, 16), true},
+		{bytes.Repeat([]byte{0x00}, 64), true},
+		{bytes.Repeat([]byte{0xff}, 15), false}, // too short to tell
+		{bytes.Repeat([]byte{0x55}, 32), false},
+		{append(bytes.Repeat([]byte{0xff}, 31), 0xfe), false},
+	}
+	for i, c := range cases {
+		if got := LooksLikeFloatingRead(c.b); got != c.exp {
+			t.Errorf("case %d: expected %v, got %v", i, c.exp, got)
+		}
+	}
+
+	var warnings []string
+	conf := EEPROM24Config{Size: 256, PageSize: 8}
+	conf.FloatingRead = func(off uint, n int) { warnings = append(warnings, fmt.Sprintf("%d+%d", off, n)) }
+	ee, mem := NewFakeEEPROM24(conf)
+	fillPattern(mem[:0x80], 0x11)
+	for i := 0x80; i < 0x100; i++ {
+		mem[i] = 0xff
+	}
+
+	r := make([]byte, 32)
+	ee.Seek(0x40, 0)
+	if n, err := ee.Read(r); n != 32 || err != nil {
+		t.Fatalf("expected to read 32 bytes, got %d, %v", n, err)
+	}
+	ee.Seek(0xe0, 0)
+	if n, err := ee.Read(r); n != 32 || err != nil {
+		t.Fatalf("expected the suspicious read to succeed, got %d, %v", n, err)
+	}
+	if fmt.Sprint(warnings) != "[224+32]" {
+		t.Fatalf("expected a single warning for the read at 224, got %v", warnings)
+	}
+}
+
+func TestEEPROM24WriteLastByte(t *testing.T) {
+	for _, conf := range []EEPROM24Config{Conf_24C02, {Size: 2048, PageSize: 16}, {Size: 1 << 16, PageSize: 64}} {
+		conf.WriteDelay = 0
+		pvt := newPVT24(conf, t)
+		ee := newTestEEPROM24(t, conf, pvt)
+		last := int64(conf.Size) - 1
+
+		// exactly the last byte
+		ee.Seek(last, 0)
+		if n, err := ee.Write([]byte{0x42}); n != 1 || err != nil {
+			t.Fatalf("size %d: expected (1, nil) for the last byte, got (%d, %v)", conf.Size, n, err)
+		}
+		if pvt.mem[last] != 0x42 {
+			t.Fatalf("size %d: last byte not written", conf.Size)
+		}
+		if p := ee.Tell(); p != int64(conf.Size) {
+			t.Fatalf("size %d: expected the file pointer at the end, got %d", conf.Size, p)
+		}
+		if n, err := ee.Write([]byte{0x43}); n != 0 || err != io.EOF {
+			t.Fatalf("size %d: expected (0, io.EOF) at the end, got (%d, %v)", conf.Size, n, err)
+		}
+
+		// two bytes at the last byte
+		ee.Seek(last, 0)
+		if n, err := ee.Write([]byte{0x44, 0x45}); n != 1 || err != io.EOF {
+			t.Fatalf("size %d: expected (1, io.EOF) for two bytes, got (%d, %v)", conf.Size, n, err)
+		}
+		if pvt.mem[last] != 0x44 {
+			t.Fatalf("size %d: last byte not written", conf.Size)
+		}
+		if p := ee.Tell(); p != int64(conf.Size) {
+			t.Fatalf("size %d: expected the file pointer at the end, got %d", conf.Size, p)
+		}
+	}
+}
+
+func TestEEPROM24ReadFull(t *testing.T) {
+	conf := EEPROM24Config{Size: 2048, PageSize: 16}
+	pvt := newPVT24(conf, t)
+	ee := newTestEEPROM24(t, conf, pvt)
+	_ee := ee.(*ee24)
+
+	// across two banks
+	ee.Seek(0xf0, 0)
+	r := make([]byte, 0x120)
+	if n, err := _ee.ReadFull(r); n != len(r) || err != nil {
+		t.Fatalf("expected to read %d bytes, got %d, %v", len(r), n, err)
+	}
+	if string(r) != string(pvt.mem[0xf0:0x210]) {
+		t.Fatalf("data read differs from memory")
+	}
+
+	// exactly up to the end
+	ee.Seek(-4, 2)
+	if n, err := _ee.ReadFull(r[:4]); n != 4 || err != nil {
+		t.Fatalf("expected to read 4 bytes, got %d, %v", n, err)
+	}
+
+	// past the end
+	ee.Seek(-4, 2)
+	if n, err := _ee.ReadFull(r[:5]); n != 4 || err != io.ErrUnexpectedEOF {
+		t.Fatalf("expected 4 bytes and io.ErrUnexpectedEOF, got %d, %v", n, err)
+	}
+	if n, err := _ee.ReadFull(r[:5]); n != 0 || err != io.EOF {
+		t.Fatalf("expected io.EOF at the end, got %d, %v", n, err)
+	}
+	if n, err := _ee.ReadFull(nil); n != 0 || err != nil {
+		t.Fatalf("expected an empty read to succeed, got %d, %v", n, err)
+	}
+}
+
+func TestEEPROM24WriteAddressOverflow(t *testing.T) {
+	conf := EEPROM24Config{Size: 256, PageSize: 16}
+	ee, mem := NewFakeEEPROM24(conf)
+	if _, err := ee.Seek(200, 0); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+
+	// a configuration shrunk behind the driver's back leaves the file
+	// pointer beyond the end of the array
+	ee.(*ee24).conf.Size = 128
+	if n, err := ee.Write([]byte{1, 2}); n != 0 || err != ErrInternalAddressOverflow {
+		t.Fatalf("expected (0, ErrInternalAddressOverflow), got (%d, %v)", n, err)
+	}
+	for _, v := range mem {
+		if v != 0 {
+			t.Fatalf("Write wrote to the EEPROM")
+		}
+	}
+
+	// a transactor reporting more bytes written than passed to it
+	ee, _ = NewFakeEEPROM24(conf)
+	ee.(*ee24).tr = TransactorFuncs(func(addr Addr, regaddr uint8, w, r []byte) (int, int, error) {
+		return len(w) + 1, 0, errors.New("bus error")
+	}, nil)
+	ee.Seek(255, 0)
+	if n, err := ee.Write([]byte{1}); n != 1 || err != ErrInternalAddressOverflow {
+		t.Fatalf("expected (1, ErrInternalAddressOverflow), got (%d, %v)", n, err)
+	}
+	if p := ee.Tell(); p != int64(conf.Size) {
+		t.Fatalf("expected the file pointer at the end, got %d", p)
+	}
+
+	// the other writes are checked as well
+	ee.Seek(255, 0)
+	if n, err := ee.(*ee24).WriteWith([]byte{1}, WriteModeDelay(0)); n != 1 || err != ErrInternalAddressOverflow {
+		t.Fatalf("WriteWith: expected (1, ErrInternalAddressOverflow), got (%d, %v)", n, err)
+	}
+	if p := ee.Tell(); p != int64(conf.Size) {
+		t.Fatalf("WriteWith: expected the file pointer at the end, got %d", p)
+	}
+	ee.Seek(255, 0)
+	if n, err := ee.(*ee24).WriteContext(context.Background(), []byte{1}); n != 1 || err != ErrInternalAddressOverflow {
+		t.Fatalf("WriteContext: expected (1, ErrInternalAddressOverflow), got (%d, %v)", n, err)
+	}
+	if n, err := ee.WriteAt([]byte{1}, 255); n != 1 || err != ErrInternalAddressOverflow {
+		t.Fatalf("WriteAt: expected (1, ErrInternalAddressOverflow), got (%d, %v)", n, err)
+	}
+}
+
+// busyProber NACKs the probes of a device for the next busy probes.
+type busyProber struct {
+	scriptedMaster
+	busy int
+}
+
+func (p *busyProber) WriteByte(b byte) error {
+	if p.busy > 0 {
+		p.busy--
+		return NACKReceived
+	}
+	return nil
+}
+
+func TestEEPROM24AckPolling(t *testing.T) {
+	conf := EEPROM24Config{Size: 256, PageSize: 8, WriteDelay: 2 * time.Millisecond, AckPolling: true}
+
+	// a device which never finishes its write cycle fails the write
+	// after WriteDelay
+	ee, _ := NewEEPROM24(&busyProber{busy: 1 << 30}, Addr7(0x50), conf)
+	ee.(*ee24).tr = newMemEEPROM24(conf)
+	start := time.Now()
+	if _, err := ee.Write([]byte{1}); err == nil {
+		t.Fatalf("write to a device which stays busy succeeded")
+	}
+	if d := time.Since(start); d < conf.WriteDelay || d > 20*conf.WriteDelay {
+		t.Errorf("expected the write to time out after about %v, took %v", conf.WriteDelay, d)
+	}
+
+	// a device busy for two polls per page
+	delays := fakeSleep(t)
+	ee, mem := NewFakeEEPROM24(conf)
+	tr := ee.(*ee24).tr
+	p := &busyProber{}
+	ee.(*ee24).m = p
+	ee.(*ee24).tr = TransactorFuncs(func(addr Addr, regaddr uint8, w, r []byte) (int, int, error) {
+		if len(w) > 0 {
+			p.busy = 2
+		}
+		return tr.Transact8x8(addr, regaddr, w, r)
+	}, nil)
+	if n, err := ee.Write([]byte{1, 2, 3, 4, 5, 6, 7, 8, 9}); n != 9 || err != nil {
+		t.Fatalf("expected to write 9 bytes, got %d, %v", n, err)
+	}
+	if string(mem[:9]) != "\x01\x02\x03\x04\x05\x06\x07\x08\x09" {
+		t.Fatalf("data written is not in the device: % x", mem[:9])
+	}
+	if exp := fmt.Sprint([]time.Duration{writePollInterval, writePollInterval, writePollInterval, writePollInterval}); fmt.Sprint(*delays) != exp {
+		t.Errorf("expected delays %s, got %v", exp, *delays)
+	}
+
+	// the device is polled with the address only probe of Ready, which
+	// leaves its address pointer alone
+	m := NewRecordingMaster(newmemdev256(Addr7(0x50)))
+	ee, _ = NewEEPROM24(m, Addr7(0x50), conf)
+	if _, err := ee.Write([]byte{1}); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	log := recorded(m)
+	checkLog(t, log[len(log)-3:], []i2cItem{{t_START, 0, false, nil},
+		{t_WRITE, 0xa0, false, nil},
+		{t_STOP, 0, false, nil},
+	})
+}
+
+func TestEEPROM24ReadAtWriteAt(t *testing.T) {
+	conf := EEPROM24Config{Size: 2048, PageSize: 16}
+	pvt := newPVT24(conf, t)
+	ee := newTestEEPROM24(t, conf, pvt)
+	ee.Seek(0x40, 0)
+
+	// across a page and a bank boundary
+	b := make([]byte, 20)
+	fillPattern(b, 0x77)
+	if n, err := ee.WriteAt(b, 0xf8); n != len(b) || err != nil {
+		t.Fatalf("expected to write %d bytes, got %d, %v", len(b), n, err)
+	}
+	if string(pvt.mem[0xf8:0xf8+len(b)]) != string(b) {
+		t.Fatalf("data written is not in the device")
+	}
+	for _, l := range pvt.log {
+		if l.nw > 0 && uint(l.regaddr)%conf.PageSize+uint(l.nw) > conf.PageSize {
+			t.Fatalf("WriteAt crossed a page boundary: %v", l)
+		}
+	}
+
+	r := make([]byte, len(b))
+	if n, err := ee.ReadAt(r, 0xf8); n != len(r) || err != nil || string(r) != string(b) {
+		t.Fatalf("expected to read back % x, got % x, %d, %v", b, r, n, err)
+	}
+
+	// reads short of the end return io.EOF
+	if n, err := ee.ReadAt(r, int64(conf.Size)-4); n != 4 || err != io.EOF {
+		t.Fatalf("expected (4, io.EOF), got (%d, %v)", n, err)
+	}
+	if n, err := ee.ReadAt(r, int64(conf.Size)); n != 0 || err != io.EOF {
+		t.Fatalf("expected (0, io.EOF), got (%d, %v)", n, err)
+	}
+	if n, err := ee.WriteAt(b, int64(conf.Size)-4); n != 4 || err != io.EOF {
+		t.Fatalf("expected (4, io.EOF), got (%d, %v)", n, err)
+	}
+	if _, err := ee.ReadAt(r, -1); err == nil {
+		t.Fatalf("negative offset accepted")
+	}
+
+	if p := ee.Tell(); p != 0x40 {
+		t.Fatalf("ReadAt and WriteAt moved the file pointer to %#x", p)
+	}
+}
+
+func TestEEPROM24Concurrent(t *testing.T) {
+	conf := EEPROM24Config{Size: 256, PageSize: 16}
+	ee, mem := NewFakeEEPROM24(conf)
+	fillPattern(mem[:64], 0x3c)
+	exp := string(mem[:64])
+	l := ee.(interface {
+		Locked(func(EEPROM24) error) error
+	})
+
+	const rounds = 200
+	errs := make(chan error, 2)
+	go func() {
+		for i := 0; i < rounds; i++ {
+			err := l.Locked(func(e EEPROM24) error {
+				if _, err := e.Seek(128, 0); err != nil {
+					return err
+				}
+				_, err := e.Write(make([]byte, 40))
+				return err
+			})
+			if err != nil {
+				errs <- err
+				return
+			}
+		}
+		errs <- nil
+	}()
+	go func() {
+		for i := 0; i < rounds; i++ {
+			b := make([]byte, 64)
+			err := l.Locked(func(e EEPROM24) error {
+				if _, err := e.Seek(0, 0); err != nil {
+					return err
+				}
+				_, err := io.ReadFull(e, b)
+				return err
+			})
+			if err != nil {
+				errs <- err
+				return
+			}
+			if string(b) != exp {
+				errs <- fmt.Errorf("round %d: Seek and Read interleaved with a Write", i)
+				return
+			}
+			// unlocked single calls are atomic as well
+			if n, err := ee.ReadAt(b, 0); n != 64 || err != nil || string(b) != exp {
+				errs <- fmt.Errorf("round %d: ReadAt returned %d, %v", i, n, err)
+				return
+			}
+		}
+		errs <- nil
+	}()
+
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// HTTPHandler returns an http.Handler serving the memory array of e.
+// GET and HEAD requests are answered with the contents of the array,
+// including support for Range requests. PUT and POST requests write the
+// request body as an image to the beginning of the array, c.f.
+// LoadEEPROM24. Images larger than the array are rejected with 413
+// before anything is written; a successful write is answered with 204.
+// If e supports Locked, like the EEPROM24s returned by NewEEPROM24,
+// writes are carried out under its lock, so they do not interleave with
+// other calls. The file pointer is not changed.
+func HTTPHandler(e EEPROM24) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var size int64
+		err := lockedDo(e, func(l EEPROM24) error {
+			var err error
+			size, err = eepromSize(l)
+			return err
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			content := io.NewSectionReader(e, 0, size)
+			http.ServeContent(w, r, "eeprom.bin", time.Time{}, content)
+
+		case http.MethodPut, http.MethodPost:
+			serveWrite(e, size, w, r)
+
+		default:
+			w.Header().Set("Allow", "GET, HEAD, PUT, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// serveWrite writes the body of r to the array of e, which holds size
+// bytes.
+func serveWrite(e EEPROM24, size int64, w http.ResponseWriter, r *http.Request) {
+	img, err := io.ReadAll(io.LimitReader(r.Body, size+1))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("reading the image: %v", err), http.StatusBadRequest)
+		return
+	}
+	if int64(len(img)) > size {
+		http.Error(w, fmt.Sprintf("image exceeds the EEPROM size of %d bytes", size), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	err = lockedDo(e, func(l EEPROM24) error {
+		defer l.Seek(l.Tell(), 0)
+		_, err := LoadEEPROM24(l, bytes.NewReader(img), nil)
+		return err
+	})
+	switch {
+	case errors.Is(err, ErrReadOnly):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEEPROM24HTTPHandler(t *testing.T) {
+	conf := EEPROM24Config{Size: 256, PageSize: 16}
+	ee, mem := NewFakeEEPROM24(conf)
+	ee.Seek(0x20, 0)
+	h := HTTPHandler(ee)
+
+	do := func(method, rng string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/eeprom", bytes.NewReader(body))
+		if rng != "" {
+			req.Header.Set("Range", rng)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	img := make([]byte, 40)
+	fillPattern(img, 0x5a)
+	if rec := do(http.MethodPut, "", img); rec.Code != http.StatusNoContent {
+		t.Fatalf("PUT: expected status 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if string(mem[:len(img)]) != string(img) {
+		t.Fatalf("PUT: EEPROM contents differ from image")
+	}
+
+	rec := do(http.MethodGet, "", nil)
+	if body, _ := io.ReadAll(rec.Body); rec.Code != http.StatusOK || string(body) != string(mem) {
+		t.Fatalf("GET: expected status 200 and the array, got %d, %d bytes", rec.Code, len(body))
+	}
+
+	rec = do(http.MethodGet, "bytes=16-23", nil)
+	if body, _ := io.ReadAll(rec.Body); rec.Code != http.StatusPartialContent || string(body) != string(img[16:24]) {
+		t.Fatalf("GET range: expected status 206 and % x, got %d, % x", img[16:24], rec.Code, body)
+	}
+
+	// images exceeding the array are not written at all
+	if rec := do(http.MethodPost, "", make([]byte, conf.Size+1)); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("POST: expected status 413, got %d", rec.Code)
+	}
+	if string(mem[:len(img)]) != string(img) {
+		t.Fatalf("POST: oversized image was written")
+	}
+
+	if rec := do(http.MethodDelete, "", nil); rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("DELETE: expected status 405, got %d", rec.Code)
+	}
+
+	if p := ee.Tell(); p != 0x20 {
+		t.Fatalf("the handler moved the file pointer to %#x", p)
+	}
+
+	conf.ReadOnly = true
+	ro, _ := NewFakeEEPROM24(conf)
+	h = HTTPHandler(ro)
+	if rec := do(http.MethodPut, "", img); rec.Code != http.StatusForbidden {
+		t.Fatalf("PUT to a read only EEPROM: expected status 403, got %d", rec.Code)
+	}
+
+	// an EEPROM24 without Locked and Sizer
+	ee, mem = NewFakeEEPROM24(EEPROM24Config{Size: 256, PageSize: 16})
+	h = HTTPHandler(struct{ EEPROM24 }{ee})
+	if rec := do(http.MethodPut, "", img); rec.Code != http.StatusNoContent || string(mem[:len(img)]) != string(img) {
+		t.Fatalf("PUT to a wrapped EEPROM24: expected status 204 and the image written, got %d", rec.Code)
+	}
+	rec = do(http.MethodGet, "", nil)
+	if body, _ := io.ReadAll(rec.Body); rec.Code != http.StatusOK || string(body) != string(mem) {
+		t.Fatalf("GET from a wrapped EEPROM24: expected status 200 and the array, got %d, %d bytes", rec.Code, len(body))
+	}
+}
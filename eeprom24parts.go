@@ -0,0 +1,81 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// writeDelayBand is the worst case write cycle time of a part for
+// supply voltages of at least minVcc.
+type writeDelayBand struct {
+	minVcc float64
+	delay  time.Duration
+}
+
+// Write cycle times by supply voltage, in descending order of minVcc.
+//
+// 2.5 V to 5.5 V: 5 ms, the maximum write cycle time (tWC/tWR) in the
+// Microchip 24LCxx datasheets (24LC01B through 24LC512) and the Atmel
+// AT24Cxx datasheets (AT24C01A through AT24C512C).
+//
+// 1.7 V to 2.5 V: 10 ms. The low voltage grades (Microchip 24AAxx,
+// Atmel AT24Cxx -1.8) specify up to 10 ms in their datasheets; the
+// longest value is used so that no part is read before its write cycle
+// completed.
+var writeDelays5ms = []writeDelayBand{{2.5, 5 * time.Millisecond}, {1.7, 10 * time.Millisecond}}
+
+const (
+	minEEPROM24Vcc = 1.7
+	maxEEPROM24Vcc = 5.5
+)
+
+var eeprom24Parts = map[string]struct {
+	size, pageSize uint
+	writeDelays    []writeDelayBand
+}{
+	"24C01":  {128, 8, writeDelays5ms},
+	"24C02":  {256, 8, writeDelays5ms},
+	"24C04":  {512, 16, writeDelays5ms},
+	"24C08":  {1024, 16, writeDelays5ms},
+	"24C16":  {2048, 16, writeDelays5ms},
+	"24C32":  {4096, 32, writeDelays5ms},
+	"24C64":  {8192, 32, writeDelays5ms},
+	"24C128": {16384, 64, writeDelays5ms},
+	"24C256": {32768, 64, writeDelays5ms},
+	"24C512": {65536, 128, writeDelays5ms},
+}
+
+// EEPROM24ConfigFor returns the configuration of the 24Cxx part name,
+// e.g. "24C02", with the worst case WriteDelay at supply voltage vcc.
+// The 24LCxx, 24AAxx and 24FCxx variants are accepted as well. vcc needs
+// to be within the parts' operating range of 1.7 V to 5.5 V.
+func EEPROM24ConfigFor(name string, vcc float64) (EEPROM24Config, error) {
+	n := strings.ToUpper(name)
+	for _, variant := range []string{"24LC", "24AA", "24FC"} {
+		if strings.HasPrefix(n, variant) {
+			n = "24C" + n[len(variant):]
+		}
+	}
+
+	part, ok := eeprom24Parts[n]
+	if !ok {
+		return EEPROM24Config{}, fmt.Errorf("EEPROM24ConfigFor: unknown part %q", name)
+	}
+	if vcc < minEEPROM24Vcc || vcc > maxEEPROM24Vcc {
+		return EEPROM24Config{}, fmt.Errorf("EEPROM24ConfigFor: supply voltage %.2f V outside of the operating range", vcc)
+	}
+
+	conf := EEPROM24Config{Size: part.size, PageSize: part.pageSize}
+	for _, b := range part.writeDelays {
+		if vcc >= b.minVcc {
+			conf.WriteDelay = b.delay
+			break
+		}
+	}
+	return conf, nil
+}
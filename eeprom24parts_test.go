@@ -0,0 +1,48 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEEPROM24ConfigFor(t *testing.T) {
+	cases := []struct {
+		name  string
+		vcc   float64
+		size  uint
+		delay time.Duration
+	}{
+		{"24C02", 5, 256, 5 * time.Millisecond},
+		{"24C02", 2.5, 256, 5 * time.Millisecond}, // lower edge of the band
+		{"24lc02", 2.49, 256, 10 * time.Millisecond},
+		{"24AA02", 1.8, 256, 10 * time.Millisecond},
+		{"24C512", 3.3, 65536, 5 * time.Millisecond},
+		{"24FC512", 1.7, 65536, 10 * time.Millisecond},
+	}
+
+	for _, c := range cases {
+		conf, err := EEPROM24ConfigFor(c.name, c.vcc)
+		if err != nil {
+			t.Fatalf("%s at %v V: unexpected error %v", c.name, c.vcc, err)
+		}
+		if conf.Size != c.size || conf.WriteDelay != c.delay {
+			t.Errorf("%s at %v V: expected size %d and write delay %v, got %d and %v", c.name, c.vcc, c.size, c.delay, conf.Size, conf.WriteDelay)
+		}
+		if _, err := NewEEPROM24(newPVT24(conf, t), Addr7(0x50), conf); err != nil {
+			t.Errorf("%s: configuration rejected by NewEEPROM24: %v", c.name, err)
+		}
+	}
+
+	if _, err := EEPROM24ConfigFor("24C03", 5); err == nil {
+		t.Errorf("unknown part accepted")
+	}
+	for _, vcc := range []float64{1.6, 6} {
+		if _, err := EEPROM24ConfigFor("24C02", vcc); err == nil {
+			t.Errorf("supply voltage %v V accepted", vcc)
+		}
+	}
+}
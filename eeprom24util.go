@@ -0,0 +1,441 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// copyChunkSize bounds the size of the transfers of CopyEEPROM24.
+const copyChunkSize = 256
+
+// CopyEEPROM24 copies the contents of src to dst, starting at the
+// beginning of both. It copies as many bytes as fit into the smaller
+// of the two. If dst is smaller than src, the copy is truncated and
+// an error is returned along with the number of bytes copied. The data
+// is transferred in chunks of at most 256 bytes, dst's Write takes care
+// of page boundaries and write cycles. The file pointers of both are
+// left after the last byte copied.
+func CopyEEPROM24(dst, src EEPROM24) (int64, error) {
+	srcsize, err := eepromSize(src)
+	if err != nil {
+		return 0, err
+	}
+	dstsize, err := eepromSize(dst)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := src.Seek(0, 0); err != nil {
+		return 0, err
+	}
+	if _, err := dst.Seek(0, 0); err != nil {
+		return 0, err
+	}
+
+	n := srcsize
+	if dstsize < n {
+		n = dstsize
+	}
+
+	var copied int64
+	buf := make([]byte, copyChunkSize)
+	for copied < n {
+		chunk := buf
+		if rem := n - copied; rem < int64(len(chunk)) {
+			chunk = chunk[:rem]
+		}
+
+		nr, err := io.ReadFull(src, chunk)
+		if err != nil {
+			return copied, err
+		}
+
+		nw, err := dst.Write(chunk[:nr])
+		copied += int64(nw)
+		if err != nil {
+			return copied, err
+		}
+	}
+
+	if dstsize < srcsize {
+		return copied, fmt.Errorf("CopyEEPROM24: destination of %d bytes filled before the end of the %d byte source", dstsize, srcsize)
+	}
+
+	return copied, nil
+}
+
+// LoadEEPROM24 writes the image read from src to e, starting at the
+// beginning of e. The image is written in chunks of e's page size, or
+// of 256 bytes if e's page size is not known, and progress, if not
+// nil, is called with the number of bytes written so far after each
+// chunk. Waiting for write cycles is up to e's Write. An image shorter
+// than e is written without an error. If src holds more data than fits
+// into e, e is filled and an error is returned. LoadEEPROM24 returns
+// the number of bytes written.
+func LoadEEPROM24(e EEPROM24, src io.Reader, progress func(uint)) (int64, error) {
+	size, err := eepromSize(e)
+	if err != nil {
+		return 0, err
+	}
+
+	chunksize := int64(copyChunkSize)
+	if p, ok := e.(pager); ok {
+		chunksize = int64(p.pageSize())
+	}
+
+	if _, err := e.Seek(0, 0); err != nil {
+		return 0, err
+	}
+
+	var written int64
+	buf := make([]byte, chunksize)
+	for written < size {
+		chunk := buf
+		if rem := size - written; rem < int64(len(chunk)) {
+			chunk = chunk[:rem]
+		}
+
+		nr, rerr := io.ReadFull(src, chunk)
+		if nr > 0 {
+			nw, err := e.Write(chunk[:nr])
+			written += int64(nw)
+			if err != nil {
+				return written, err
+			}
+			if progress != nil {
+				progress(uint(written))
+			}
+		}
+
+		if rerr == io.EOF || rerr == io.ErrUnexpectedEOF {
+			return written, nil
+		}
+		if rerr != nil {
+			return written, rerr
+		}
+	}
+
+	// e is full, src must be exhausted
+	var extra [1]byte
+	if n, err := io.ReadFull(src, extra[:]); n > 0 {
+		return written, fmt.Errorf("LoadEEPROM24: image exceeds the EEPROM size of %d bytes", size)
+	} else if err != io.EOF {
+		return written, err
+	}
+
+	return written, nil
+}
+
+// FlashEEPROM24 writes image to e, starting at the beginning of e, and
+// verifies it by reading it back. The image is written in chunks of e's
+// page size, or of 256 bytes if e's page size is not known, and read
+// back in chunks of 256 bytes. progress, if not nil, is called after
+// each chunk with the phase, "write" or "verify", the number of bytes
+// done in that phase and the size of the image. Errors name the phase
+// and the offset at which it failed, for a failed verification the
+// offset of the first byte differing from the image.
+func FlashEEPROM24(e EEPROM24, image []byte, progress func(phase string, done, total uint)) error {
+	size, err := eepromSize(e)
+	if err != nil {
+		return err
+	}
+	if int64(len(image)) > size {
+		return fmt.Errorf("FlashEEPROM24: image of %d bytes exceeds the EEPROM size of %d bytes", len(image), size)
+	}
+
+	chunksize := copyChunkSize
+	if p, ok := e.(pager); ok {
+		chunksize = int(p.pageSize())
+	}
+	total := uint(len(image))
+
+	if _, err := e.Seek(0, 0); err != nil {
+		return fmt.Errorf("FlashEEPROM24: write at 0x%04x: %w", 0, err)
+	}
+	for off := 0; off < len(image); off += chunksize {
+		chunk := image[off:]
+		if len(chunk) > chunksize {
+			chunk = chunk[:chunksize]
+		}
+		n, err := e.Write(chunk)
+		if err != nil {
+			return fmt.Errorf("FlashEEPROM24: write at 0x%04x: %w", off+n, err)
+		}
+		if progress != nil {
+			progress("write", uint(off+n), total)
+		}
+	}
+
+	if _, err := e.Seek(0, 0); err != nil {
+		return fmt.Errorf("FlashEEPROM24: verify at 0x%04x: %w", 0, err)
+	}
+	buf := make([]byte, copyChunkSize)
+	for off := 0; off < len(image); off += copyChunkSize {
+		want := image[off:]
+		if len(want) > copyChunkSize {
+			want = want[:copyChunkSize]
+		}
+		got := buf[:len(want)]
+		n, err := io.ReadFull(e, got)
+		if err != nil {
+			return fmt.Errorf("FlashEEPROM24: verify at 0x%04x: %w", off+n, err)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				return fmt.Errorf("FlashEEPROM24: verify: mismatch at 0x%04x: read %#02x, expected %#02x", off+i, got[i], want[i])
+			}
+		}
+		if progress != nil {
+			progress("verify", uint(off+n), total)
+		}
+	}
+
+	return nil
+}
+
+// diffRegion is a region of consecutive differing bytes found by
+// DiffReport.
+type diffRegion struct {
+	off  uint
+	a, b []byte
+}
+
+// addDiffs appends the differences between a and b, which are at
+// position off, to regions. A difference directly following the last
+// region extends it.
+func addDiffs(regions []diffRegion, off uint, a, b []byte) []diffRegion {
+	for i := range a {
+		if a[i] == b[i] {
+			continue
+		}
+		pos := off + uint(i)
+		if n := len(regions); n > 0 && regions[n-1].off+uint(len(regions[n-1].a)) == pos {
+			regions[n-1].a = append(regions[n-1].a, a[i])
+			regions[n-1].b = append(regions[n-1].b, b[i])
+			continue
+		}
+		regions = append(regions, diffRegion{pos, []byte{a[i]}, []byte{b[i]}})
+	}
+	return regions
+}
+
+// DiffReport compares the contents of a and b and writes a report of
+// the regions in which they differ to w, one line per region of
+// consecutive differing bytes with its position and the bytes of a and
+// b in hex. If the sizes differ, the common part is compared and the
+// sizes are reported. Both EEPROMs are read from the beginning in
+// chunks of 256 bytes, their file pointers are left after the compared
+// part.
+func DiffReport(a, b EEPROM24, w io.Writer) error {
+	asize, err := eepromSize(a)
+	if err != nil {
+		return err
+	}
+	bsize, err := eepromSize(b)
+	if err != nil {
+		return err
+	}
+
+	if _, err := a.Seek(0, 0); err != nil {
+		return err
+	}
+	if _, err := b.Seek(0, 0); err != nil {
+		return err
+	}
+
+	n := asize
+	if bsize < n {
+		n = bsize
+	}
+
+	var regions []diffRegion
+	abuf := make([]byte, copyChunkSize)
+	bbuf := make([]byte, copyChunkSize)
+	for off := int64(0); off < n; off += copyChunkSize {
+		achunk, bchunk := abuf, bbuf
+		if rem := n - off; rem < copyChunkSize {
+			achunk, bchunk = achunk[:rem], bchunk[:rem]
+		}
+		if _, err := io.ReadFull(a, achunk); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(b, bchunk); err != nil {
+			return err
+		}
+		regions = addDiffs(regions, uint(off), achunk, bchunk)
+	}
+
+	if asize != bsize {
+		if _, err := fmt.Fprintf(w, "sizes differ: %d bytes vs %d bytes, comparing the first %d bytes\n", asize, bsize, n); err != nil {
+			return err
+		}
+	}
+	if len(regions) == 0 {
+		_, err := fmt.Fprintf(w, "no differences\n")
+		return err
+	}
+
+	for _, r := range regions {
+		var err error
+		if len(r.a) == 1 {
+			_, err = fmt.Fprintf(w, "0x%04x (1 byte): % x -> % x\n", r.off, r.a, r.b)
+		} else {
+			_, err = fmt.Fprintf(w, "0x%04x-0x%04x (%d bytes): % x -> % x\n", r.off, r.off+uint(len(r.a))-1, len(r.a), r.a, r.b)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Range is a region of the memory array of an EEPROM24.
+type Range struct {
+	Off, Len uint
+}
+
+// ReadRanges reads each of ranges from e and returns the data in the
+// order of ranges. Overlapping and adjacent ranges are coalesced and
+// read in one go. All ranges need to lie within the array. If e
+// supports Locked, the ranges are read under its lock. The file pointer
+// is not changed.
+func ReadRanges(e EEPROM24, ranges []Range) ([][]byte, error) {
+	var out [][]byte
+	err := lockedDo(e, func(l EEPROM24) error {
+		size, err := eepromSize(l)
+		if err != nil {
+			return err
+		}
+		for _, r := range ranges {
+			if uint64(r.Off)+uint64(r.Len) > uint64(size) {
+				return fmt.Errorf("ReadRanges: %d bytes at position %d exceed the array size of %d bytes", r.Len, r.Off, size)
+			}
+		}
+
+		// ranges in order of their position
+		order := make([]int, len(ranges))
+		for i := range order {
+			order[i] = i
+		}
+		sort.Slice(order, func(i, j int) bool { return ranges[order[i]].Off < ranges[order[j]].Off })
+
+		out = make([][]byte, len(ranges))
+		for i := 0; i < len(order); {
+			// extend the span while the next range overlaps or adjoins it
+			start, end := ranges[order[i]].Off, ranges[order[i]].Off+ranges[order[i]].Len
+			j := i + 1
+			for ; j < len(order) && ranges[order[j]].Off <= end; j++ {
+				if rend := ranges[order[j]].Off + ranges[order[j]].Len; rend > end {
+					end = rend
+				}
+			}
+
+			span := make([]byte, end-start)
+			if len(span) > 0 {
+				// the span may end at the end of the array, where
+				// ReadAt may return io.EOF along with all bytes
+				if n, err := l.ReadAt(span, int64(start)); n < len(span) {
+					if err == nil || err == io.EOF {
+						err = io.ErrUnexpectedEOF
+					}
+					return err
+				}
+			}
+
+			for _, k := range order[i:j] {
+				r := ranges[k]
+				out[k] = append([]byte(nil), span[r.Off-start:r.Off-start+r.Len]...)
+			}
+			i = j
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// FindEEPROM24 looks for EEPROMs of type family at the addresses 0x50
+// to 0x57, which 24Cxx devices select by their address pins. Devices of
+// up to 256 bytes can be found at every address, larger 8+3 bit
+// addressed devices occupy several consecutive addresses and can only
+// be found at multiples of their number of addresses. Every candidate
+// is probed with an address write and then confirmed with a read of
+// a single byte from the device's current address, so no data is
+// written and no address pointer is set. The base addresses of the
+// devices found are returned.
+func FindEEPROM24(m I2CMaster, family EEPROM24Config) ([]Addr7, error) {
+	if family.Size == 0 || !ispow2(uint64(family.Size)) {
+		return nil, errors.New("FindEEPROM24: array size needs to be a power of 2")
+	}
+
+	step := len(family.AddressMap(0x50))
+	if step > 8 {
+		return nil, errors.New("FindEEPROM24: EEPROM occupies more than 8 addresses")
+	}
+
+	var found []Addr7
+	for a := Addr7(0x50); a <= 0x57; a += Addr7(step) {
+		ok, err := probe(m, a)
+		if err != nil {
+			return found, err
+		}
+		if !ok {
+			continue
+		}
+
+		ok, err = readCurrent(m, a)
+		if err != nil {
+			return found, err
+		}
+		if ok {
+			found = append(found, a)
+		}
+	}
+
+	return found, nil
+}
+
+// readCurrent reads one byte from the current address of the device at
+// addr. It reports whether the device ACKed its read address.
+func readCurrent(m I2CMaster, addr Addr) (bool, error) {
+	var b [1]byte
+	_, err := currentAddressRead(m, addr, b[:])
+	if err == NoSuchDevice {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// currentAddressRead addresses the device at addr for reading without
+// setting its address pointer first and reads len(r) bytes from where
+// the pointer currently is. A NACK of the address is reported as
+// NoSuchDevice.
+func currentAddressRead(m I2CMaster, addr Addr, r []byte) (int, error) {
+	if err := m.Start(); err != nil {
+		return 0, err
+	}
+
+	if err := m.WriteByte(uint8(addr.GetBaseAddr()<<1) | 0x01); err != nil {
+		m.Stop()
+		if err == NACKReceived {
+			return 0, NoSuchDevice
+		}
+		return 0, err
+	}
+
+	n, err := ReadBlock(m, r, false)
+	if err != nil {
+		m.Stop()
+		return n, err
+	}
+
+	return n, m.Stop()
+}
@@ -0,0 +1,252 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func fillPattern(b []byte, seed uint8) {
+	for i := range b {
+		b[i] = seed ^ uint8(i) ^ uint8(i>>8)
+	}
+}
+
+func TestCopyEEPROM24(t *testing.T) {
+	// small to big: everything is copied
+	src, srcmem := NewFakeEEPROM24(Conf_24C02)
+	dst, dstmem := NewFakeEEPROM24(EEPROM24Config{Size: 2048, PageSize: 16})
+	fillPattern(srcmem, 0x5a)
+
+	n, err := CopyEEPROM24(dst, src)
+	if n != int64(len(srcmem)) || err != nil {
+		t.Fatalf("expected to copy %d bytes, got %d, %v", len(srcmem), n, err)
+	}
+	if string(dstmem[:n]) != string(srcmem) {
+		t.Fatalf("destination contents differ from source")
+	}
+
+	// big to small: truncated with an error
+	src, srcmem = NewFakeEEPROM24(EEPROM24Config{Size: 2048, PageSize: 16})
+	dst, dstmem = NewFakeEEPROM24(Conf_24C02)
+	fillPattern(srcmem, 0xa5)
+
+	n, err = CopyEEPROM24(dst, src)
+	if n != int64(len(dstmem)) || err == nil {
+		t.Fatalf("expected to copy %d bytes and an error, got %d, %v", len(dstmem), n, err)
+	}
+	if string(dstmem) != string(srcmem[:n]) {
+		t.Fatalf("destination contents differ from source")
+	}
+}
+
+func TestLoadEEPROM24(t *testing.T) {
+	conf := EEPROM24Config{Size: 256, PageSize: 16}
+
+	img := make([]byte, 100)
+	fillPattern(img, 0x3c)
+	e, mem := NewFakeEEPROM24(conf)
+	var progress []uint
+	n, err := LoadEEPROM24(e, bytes.NewReader(img), func(n uint) { progress = append(progress, n) })
+	if n != 100 || err != nil {
+		t.Fatalf("expected to write 100 bytes, got %d, %v", n, err)
+	}
+	if string(mem[:100]) != string(img) {
+		t.Fatalf("EEPROM contents differ from image")
+	}
+	if fmt.Sprint(progress) != "[16 32 48 64 80 96 100]" {
+		t.Fatalf("unexpected progress %v", progress)
+	}
+
+	// an image of exactly the EEPROM's size fits
+	img = make([]byte, conf.Size)
+	fillPattern(img, 0xc3)
+	e, mem = NewFakeEEPROM24(conf)
+	if n, err := LoadEEPROM24(e, bytes.NewReader(img), nil); n != int64(conf.Size) || err != nil {
+		t.Fatalf("expected to write %d bytes, got %d, %v", conf.Size, n, err)
+	}
+	if string(mem) != string(img) {
+		t.Fatalf("EEPROM contents differ from image")
+	}
+
+	// an image which is too big fills the EEPROM and fails
+	img = make([]byte, conf.Size+1)
+	e, _ = NewFakeEEPROM24(conf)
+	if n, err := LoadEEPROM24(e, bytes.NewReader(img), nil); n != int64(conf.Size) || err == nil {
+		t.Fatalf("expected to write %d bytes and an error, got %d, %v", conf.Size, n, err)
+	}
+}
+
+func TestFindEEPROM24(t *testing.T) {
+	bus := &memBus{devs: []*memdev256{
+		newmemdev256(Addr7(0x48)),
+		newmemdev256(Addr7(0x52)),
+		newmemdev256(Addr7(0x53)),
+		newmemdev256(Addr7(0x56)),
+	}}
+	m := NewBusSanityMaster(bus)
+
+	found, err := FindEEPROM24(m, Conf_24C02)
+	if err != nil || fmt.Sprint(found) != fmt.Sprint([]Addr7{0x52, 0x53, 0x56}) {
+		t.Fatalf("expected 24C02s at 0x52, 0x53 and 0x56, got %v, %v", found, err)
+	}
+
+	// 24C04s occupy two addresses each
+	found, err = FindEEPROM24(m, EEPROM24Config{Size: 512, PageSize: 16})
+	if err != nil || fmt.Sprint(found) != fmt.Sprint([]Addr7{0x52, 0x56}) {
+		t.Fatalf("expected 24C04s at 0x52 and 0x56, got %v, %v", found, err)
+	}
+
+	for _, d := range bus.devs {
+		for _, v := range d.mem {
+			if v != 0 {
+				t.Fatalf("FindEEPROM24 wrote to device %v", d.addr)
+			}
+		}
+	}
+}
+
+func TestDiffReport(t *testing.T) {
+	a, amem := NewFakeEEPROM24(EEPROM24Config{Size: 1024, PageSize: 16})
+	b, bmem := NewFakeEEPROM24(EEPROM24Config{Size: 1024, PageSize: 16})
+
+	var out bytes.Buffer
+	if err := DiffReport(a, b, &out); err != nil || out.String() != "no differences\n" {
+		t.Fatalf("expected no differences, got %q, %v", out.String(), err)
+	}
+
+	bmem[0x10] = 0xff                     // scattered
+	bmem[0x12] = 0xfe                     // not adjacent to the previous one
+	copy(bmem[0xfe:], []byte{1, 2, 3, 4}) // contiguous across a chunk boundary
+	amem[0x3ff], bmem[0x3ff] = 0x55, 0xaa // last byte
+
+	out.Reset()
+	if err := DiffReport(a, b, &out); err != nil {
+		t.Fatalf("DiffReport failed: %v", err)
+	}
+	exp := "0x0010 (1 byte): 00 -> ff\n" +
+		"0x0012 (1 byte): 00 -> fe\n" +
+		"0x00fe-0x0101 (4 bytes): 00 00 00 00 -> 01 02 03 04\n" +
+		"0x03ff (1 byte): 55 -> aa\n"
+	if out.String() != exp {
+		t.Fatalf("expected report\n%s\ngot\n%s", exp, out.String())
+	}
+
+	// different sizes compare the common part
+	c, _ := NewFakeEEPROM24(EEPROM24Config{Size: 256, PageSize: 16})
+	out.Reset()
+	if err := DiffReport(a, c, &out); err != nil {
+		t.Fatalf("DiffReport failed: %v", err)
+	}
+	exp = "sizes differ: 1024 bytes vs 256 bytes, comparing the first 256 bytes\nno differences\n"
+	if out.String() != exp {
+		t.Fatalf("expected report\n%s\ngot\n%s", exp, out.String())
+	}
+}
+
+func TestFlashEEPROM24(t *testing.T) {
+	conf := EEPROM24Config{Size: 256, PageSize: 16}
+
+	img := make([]byte, 40)
+	fillPattern(img, 0x69)
+	e, mem := NewFakeEEPROM24(conf)
+	var progress []string
+	err := FlashEEPROM24(e, img, func(phase string, done, total uint) {
+		progress = append(progress, fmt.Sprintf("%s %d/%d", phase, done, total))
+	})
+	if err != nil {
+		t.Fatalf("FlashEEPROM24 failed: %v", err)
+	}
+	if string(mem[:len(img)]) != string(img) {
+		t.Fatalf("EEPROM contents differ from image")
+	}
+	if exp := "[write 16/40 write 32/40 write 40/40 verify 40/40]"; fmt.Sprint(progress) != exp {
+		t.Fatalf("expected progress %s, got %v", exp, progress)
+	}
+
+	// a byte not sticking is reported by its offset
+	e, mem = NewFakeEEPROM24(conf)
+	tr := e.(*ee24).tr
+	e.(*ee24).tr = TransactorFuncs(func(addr Addr, regaddr uint8, w, r []byte) (int, int, error) {
+		nw, nr, err := tr.Transact8x8(addr, regaddr, w, r)
+		if len(w) > 0 && regaddr <= 0x13 && int(regaddr)+len(w) > 0x13 {
+			mem[0x13] ^= 0x01
+		}
+		return nw, nr, err
+	}, nil)
+	err = FlashEEPROM24(e, img, nil)
+	if err == nil || !strings.Contains(err.Error(), "verify: mismatch at 0x0013") {
+		t.Fatalf("expected a verify mismatch at 0x0013, got %v", err)
+	}
+
+	// write errors name the phase
+	e, _ = NewFakeEEPROM24(conf)
+	e.(*ee24).tr = TransactorFuncs(func(addr Addr, regaddr uint8, w, r []byte) (int, int, error) {
+		return 0, 0, NoSuchDevice
+	}, nil)
+	if err := FlashEEPROM24(e, img, nil); !errors.Is(err, NoSuchDevice) || !strings.Contains(err.Error(), "write at 0x0000") {
+		t.Fatalf("expected a write error at 0x0000, got %v", err)
+	}
+
+	if err := FlashEEPROM24(e, make([]byte, 257), nil); err == nil {
+		t.Fatalf("image exceeding the EEPROM accepted")
+	}
+}
+
+func TestReadRanges(t *testing.T) {
+	conf := EEPROM24Config{Size: 256, PageSize: 8}
+	pvt := newPVT24(conf, t)
+	ee := newTestEEPROM24(t, conf, pvt)
+
+	ranges := []Range{
+		{100, 4}, // alone
+		{12, 10}, // overlaps the next one
+		{10, 5},
+		{22, 3}, // adjacent to the previous ones
+		{50, 0}, // empty
+		{250, 6},
+	}
+	data, err := ReadRanges(ee, ranges)
+	if err != nil {
+		t.Fatalf("ReadRanges failed: %v", err)
+	}
+
+	for i, r := range ranges {
+		if string(data[i]) != string(pvt.mem[r.Off:r.Off+r.Len]) {
+			t.Errorf("range %d: expected % x, got % x", i, pvt.mem[r.Off:r.Off+r.Len], data[i])
+		}
+	}
+
+	// [10, 25), [100, 104) and [250, 256)
+	if len(pvt.log) != 3 {
+		t.Fatalf("expected 3 transactions, got %d: %v", len(pvt.log), pvt.log)
+	}
+	if pvt.log[0].regaddr != 10 || pvt.log[0].nr != 15 {
+		t.Fatalf("expected the first transaction to read 15 bytes at 10, got %+v", pvt.log[0])
+	}
+
+	// the returned slices do not alias each other
+	data[1][0] ^= 0xff
+	if data[2][2] != pvt.mem[12] {
+		t.Fatalf("overlapping ranges share memory")
+	}
+
+	if _, err := ReadRanges(ee, []Range{{0, 1}, {255, 2}}); err == nil {
+		t.Fatalf("ReadRanges accepted a range past the end")
+	}
+	if ee.Tell() != 0 {
+		t.Fatalf("ReadRanges moved the file pointer to %d", ee.Tell())
+	}
+
+	// an EEPROM24 without Locked and Sizer
+	data, err = ReadRanges(struct{ EEPROM24 }{ee}, []Range{{250, 6}, {0, 2}})
+	if err != nil || string(data[0]) != string(pvt.mem[250:]) || string(data[1]) != string(pvt.mem[:2]) {
+		t.Fatalf("expected % x and % x, got %v, %v", pvt.mem[250:], pvt.mem[:2], data, err)
+	}
+}
@@ -12,3 +12,24 @@ var NACKReceived = errors.New("NACK received")
 // NoSuchDevice signals that no device responded
 // with an ACK at the desired address.
 var NoSuchDevice = errors.New("no such device")
+
+// ErrReadPhaseStartFailed signals that a transaction failed while
+// starting its read phase, i.e. after the write phase was carried out
+// successfully, so that the device may have acted on the data written.
+// The errors returned wrap both ErrReadPhaseStartFailed and the error
+// of the master, use errors.Is to test for them.
+var ErrReadPhaseStartFailed = errors.New("I2C transaction: starting the read phase failed")
+
+// ErrDeviceIDUnsupported signals that a device ID read was NACKed,
+// either by all devices or by the target device.
+var ErrDeviceIDUnsupported = errors.New("I2C device ID: not supported")
+
+// ErrBusUnhealthy signals that more consecutive transactions than
+// allowed failed with a NACK, c.f. WithMaxConsecutiveNACKs. The errors
+// returned wrap both ErrBusUnhealthy and the NACK error.
+var ErrBusUnhealthy = errors.New("I2C bus unhealthy: too many consecutive NACKs")
+
+// ErrReadPhaseSkipped signals that the write phase of a transaction
+// was ended by a NACK of a data byte, c.f. StopOnNACK, so that the read
+// phase requested was not carried out.
+var ErrReadPhaseSkipped = errors.New("I2C transaction: write phase ended by a NACK, read phase skipped")
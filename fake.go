@@ -0,0 +1,185 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"errors"
+	"fmt"
+)
+
+var errFakeLowLevel = errors.New("fake EEPROM: low level I2C access is not supported")
+
+// fakeProber is the low level I2CMaster of the fake EEPROMs. It only
+// supports probes, i.e. a device address for writing followed by a
+// stop condition, c.f. EEPROM24.Ready. As the fakes complete writes
+// instantly, the device addresses 0x50 to 0x57 are always ACKed.
+type fakeProber struct {
+	started   bool
+	addressed bool // the address byte was sent since the start
+}
+
+func (p *fakeProber) Start() error {
+	p.started, p.addressed = true, false
+	return nil
+}
+
+func (p *fakeProber) Stop() error {
+	p.started = false
+	return nil
+}
+
+func (p *fakeProber) WriteByte(b byte) error {
+	if !p.started || p.addressed || b&0x01 != 0 {
+		return errFakeLowLevel
+	}
+	p.addressed = true
+	if b>>1&^0x07 != 0x50 {
+		return NACKReceived
+	}
+	return nil
+}
+
+func (p *fakeProber) ReadByte(ack bool) (byte, error) { return 0, errFakeLowLevel }
+
+// memEEPROM24 is an in-memory 24Cxx EEPROM which is accessed via the
+// Transactor interfaces. Like the real devices, page writes roll over
+// at the end of the page and sequential reads roll over at the end of
+// the memory array.
+type memEEPROM24 struct {
+	fakeProber
+
+	mem      []byte
+	pagesize uint
+	small    bool
+}
+
+func newMemEEPROM24(conf EEPROM24Config) *memEEPROM24 {
+	return &memEEPROM24{
+		mem:      make([]byte, conf.Size),
+		pagesize: conf.PageSize,
+		small:    conf.hasSmallAddresses(),
+	}
+}
+
+func (d *memEEPROM24) transact(memaddr uint, w, r []byte) (int, int, error) {
+	size := uint(len(d.mem))
+	memaddr &= size - 1
+
+	pagebase := memaddr &^ (d.pagesize - 1)
+	for _, b := range w {
+		d.mem[memaddr] = b
+		memaddr = pagebase | ((memaddr + 1) & (d.pagesize - 1))
+	}
+
+	for i := range r {
+		r[i] = d.mem[memaddr]
+		memaddr = (memaddr + 1) & (size - 1)
+	}
+
+	return len(w), len(r), nil
+}
+
+func (d *memEEPROM24) Transact8x8(addr Addr, regaddr uint8, w, r []byte) (int, int, error) {
+	memaddr := (uint(addr.GetBaseAddr())&0x07)<<8 | uint(regaddr)
+	return d.transact(memaddr, w, r)
+}
+
+func (d *memEEPROM24) Transact16x8(addr Addr, regaddr uint16, w, r []byte) (int, int, error) {
+	memaddr := (uint(addr.GetBaseAddr())&0x07)<<16 | uint(regaddr)
+	return d.transact(memaddr, w, r)
+}
+
+// NewFakeEEPROM24 returns an EEPROM24 backed by memory instead of an
+// I2C device, for testing code built on EEPROM24 without hardware. The
+// returned slice is the memory array of the fake device. The EEPROM24
+// is the driver returned by NewEEPROM24, so its behavior regarding
+// seeking, page boundaries and EOF is that of a real device's driver.
+// As the fake completes writes instantly, WriteDelay and WriteTimeout
+// of conf are ignored, so that tests do not wait for write cycles.
+// NewFakeEEPROM24 panics if conf is invalid.
+func NewFakeEEPROM24(conf EEPROM24Config) (EEPROM24, []byte) {
+	conf.WriteDelay, conf.WriteTimeout = 0, 0
+	d := newMemEEPROM24(conf)
+	e, err := NewEEPROM24(d, Addr7(0xa0>>1), conf)
+	if err != nil {
+		panic(err)
+	}
+	return e, d.mem
+}
+
+// pageVerifier checks the transactions carried out on a memEEPROM24
+// against the constraints of 24Cxx devices.
+type pageVerifier struct {
+	d           *memEEPROM24
+	onViolation func(string)
+}
+
+// NewPageVerifier returns a Transactor emulating a 24Cxx EEPROM with
+// configuration conf at device address 0x50, which checks that the
+// transactions carried out on it respect the device's constraints, for
+// testing drivers of paged memories. Each violation is reported to
+// onViolation, after which the transaction is carried out like the
+// device would, e.g. with writes rolling over within the page. The
+// checks are:
+//   - writes stay within a page
+//   - reads do not roll over at the end of the memory array
+//   - transactions do not both write data and read
+//   - the register address width matches the device's addressing mode
+//   - the device address and register address lie within the array
+//
+// The returned Transactor also implements I2CMaster, so that it can be
+// passed to NewEEPROM24. Apart from probes of the device, which is
+// always ready, all low level accesses fail.
+func NewPageVerifier(conf EEPROM24Config, onViolation func(string)) Transactor {
+	return &pageVerifier{newMemEEPROM24(conf), onViolation}
+}
+
+func (v *pageVerifier) violation(format string, args ...interface{}) {
+	v.onViolation(fmt.Sprintf(format, args...))
+}
+
+func (v *pageVerifier) check(addr Addr, memaddr uint, w, r []byte) {
+	size := uint(len(v.d.mem))
+
+	if base := addr.GetBaseAddr(); base&^0x07 != 0x50 {
+		v.violation("transaction addressed to device %#02x", base)
+	}
+	if memaddr >= size {
+		v.violation("transaction at %#x beyond the end of the array at %#x", memaddr, size)
+		return
+	}
+	if len(w) > 0 && len(r) > 0 {
+		v.violation("transaction at %#x writes %d bytes and reads %d bytes", memaddr, len(w), len(r))
+	}
+	if aip := memaddr & (v.d.pagesize - 1); aip+uint(len(w)) > v.d.pagesize {
+		v.violation("write of %d bytes at %#x crosses the page boundary at %#x", len(w), memaddr, memaddr-aip+v.d.pagesize)
+	}
+	if memaddr+uint(len(r)) > size {
+		v.violation("read of %d bytes at %#x rolls over at the end of the array", len(r), memaddr)
+	}
+}
+
+func (v *pageVerifier) Transact8x8(addr Addr, regaddr uint8, w, r []byte) (int, int, error) {
+	if !v.d.small {
+		v.violation("8 bit register address used on a device with 16 bit register addresses")
+	}
+	memaddr := (uint(addr.GetBaseAddr())&0x07)<<8 | uint(regaddr)
+	v.check(addr, memaddr, w, r)
+	return v.d.transact(memaddr, w, r)
+}
+
+func (v *pageVerifier) Transact16x8(addr Addr, regaddr uint16, w, r []byte) (int, int, error) {
+	if v.d.small {
+		v.violation("16 bit register address used on a device with 8 bit register addresses")
+	}
+	memaddr := (uint(addr.GetBaseAddr())&0x07)<<16 | uint(regaddr)
+	v.check(addr, memaddr, w, r)
+	return v.d.transact(memaddr, w, r)
+}
+
+func (v *pageVerifier) Start() error                    { return v.d.Start() }
+func (v *pageVerifier) Stop() error                     { return v.d.Stop() }
+func (v *pageVerifier) WriteByte(b byte) error          { return v.d.WriteByte(b) }
+func (v *pageVerifier) ReadByte(ack bool) (byte, error) { return v.d.ReadByte(ack) }
@@ -0,0 +1,102 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"io"
+	"testing"
+)
+
+func TestFakeEEPROM24(t *testing.T) {
+	delays := fakeSleep(t)
+
+	for _, conf := range []EEPROM24Config{Conf_24C02, Conf_24C128, {Size: 2048, PageSize: 16}} {
+		ee, mem := NewFakeEEPROM24(conf)
+		if uint(len(mem)) != conf.Size {
+			t.Fatalf("expected backing memory of %d bytes, got %d", conf.Size, len(mem))
+		}
+
+		// write across a page boundary and, for small devices, across
+		// an I2C device address boundary
+		wb := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+		off := int64(conf.Size) - 261
+		if off < 0 {
+			off = int64(conf.Size) - 12
+		}
+		ee.Seek(off, 0)
+		if n, err := ee.Write(wb); n != len(wb) || err != nil {
+			t.Fatalf("expected to write %d bytes, got %d, %v", len(wb), n, err)
+		}
+		if string(mem[off:off+int64(len(wb))]) != string(wb) {
+			t.Fatalf("expected memory to contain % x, it contains % x", wb, mem[off:off+int64(len(wb))])
+		}
+
+		ee.Seek(off, 0)
+		rb := make([]byte, len(wb))
+		if _, err := io.ReadFull(ee, rb); err != nil {
+			t.Fatalf("could not read back: %v", err)
+		}
+		if string(rb) != string(wb) {
+			t.Fatalf("expected to read back % x, read % x", wb, rb)
+		}
+
+		// short read and EOF at the end of the array
+		ee.Seek(-2, 2)
+		if n, err := ee.Read(rb); n != 2 || err != nil {
+			t.Fatalf("expected a short read of 2 bytes, got %d, %v", n, err)
+		}
+		if n, err := ee.Read(rb); n != 0 || err != io.EOF {
+			t.Fatalf("expected (0, io.EOF) at the end of the array, got %d, %v", n, err)
+		}
+	}
+
+	// the write delays of the configurations are not waited for
+	if len(*delays) != 0 {
+		t.Fatalf("expected no delays, got %v", *delays)
+	}
+}
+
+func TestPageVerifier(t *testing.T) {
+	fakeSleep(t)
+
+	var violations []string
+	record := func(s string) { violations = append(violations, s) }
+
+	// the EEPROM driver respects all constraints
+	for _, conf := range []EEPROM24Config{Conf_24C02, Conf_24C128, {Size: 2048, PageSize: 16}} {
+		ee := newTestEEPROM24(t, conf, NewPageVerifier(conf, record).(I2CMaster))
+		b := make([]byte, conf.Size)
+		fillPattern(b, 0x11)
+		if n, err := ee.Write(b); n != len(b) || err != nil {
+			t.Fatalf("expected to write %d bytes, got %d, %v", len(b), n, err)
+		}
+		ee.Seek(0, 0)
+		if _, err := io.ReadFull(ee, b); err != nil {
+			t.Fatalf("could not read back: %v", err)
+		}
+	}
+	if len(violations) != 0 {
+		t.Fatalf("the EEPROM driver violated constraints: %v", violations)
+	}
+
+	cases := []struct {
+		name string
+		op   func(tr Transactor)
+	}{
+		{"page crossing write", func(tr Transactor) { tr.Transact8x8(Addr7(0x50), 6, []byte{1, 2, 3}, nil) }},
+		{"rolling read", func(tr Transactor) { tr.Transact8x8(Addr7(0x50), 0xff, nil, make([]byte, 2)) }},
+		{"write and read", func(tr Transactor) { tr.Transact8x8(Addr7(0x50), 0, []byte{1}, make([]byte, 1)) }},
+		{"wrong register address width", func(tr Transactor) { tr.Transact16x8(Addr7(0x50), 0, nil, make([]byte, 1)) }},
+		{"device address beyond the array", func(tr Transactor) { tr.Transact8x8(Addr7(0x51), 0, nil, make([]byte, 1)) }},
+		{"foreign device", func(tr Transactor) { tr.Transact8x8(Addr7(0x48), 0, nil, make([]byte, 1)) }},
+	}
+	for _, c := range cases {
+		violations = nil
+		c.op(NewPageVerifier(Conf_24C02, record))
+		if len(violations) != 1 {
+			t.Errorf("%s: expected a single violation, got %v", c.name, violations)
+		}
+	}
+}
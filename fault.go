@@ -0,0 +1,128 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"time"
+)
+
+// FaultOp is the kind of bus operation a Fault applies to.
+type FaultOp int
+
+const (
+	FaultStart FaultOp = iota
+	FaultStop
+	FaultWrite
+	FaultRead
+)
+
+// Fault describes a fault injected by NewFaultInjector. A fault is
+// triggered by the Index-th operation of kind Op, counting from 0, or,
+// if MatchByte is set, by every write of Byte or read returning Byte.
+// The triggering operation is carried out on the underlying master and
+// its outcome is then altered: Corrupt is XORed into the byte read and,
+// if Err is not nil, Err is returned instead of the operation's error.
+// Delay is waited for before the operation, e.g. to provoke timeouts
+// in the caller, except for faults matching the byte read, which is
+// only known after the operation.
+type Fault struct {
+	Op        FaultOp
+	Index     int
+	MatchByte bool
+	Byte      byte
+
+	Err     error
+	Corrupt byte
+	Delay   time.Duration
+}
+
+type faultInjector struct {
+	m      I2CMaster
+	plan   []Fault
+	counts [FaultRead + 1]int
+}
+
+// NewFaultInjector returns an I2CMaster which carries out all operations
+// on m but injects the faults in plan, for testing error handling
+// without faulty hardware. Several faults may apply to one operation,
+// their effects are combined.
+func NewFaultInjector(m I2CMaster, plan []Fault) I2CMaster {
+	return &faultInjector{m: m, plan: plan}
+}
+
+// faults returns the faults triggered by the next operation of kind op
+// writing byte b and waits for their delays. For reads, the faults
+// matching the byte read are returned as candidates.
+func (f *faultInjector) faults(op FaultOp, b byte) []*Fault {
+	idx := f.counts[op]
+	f.counts[op]++
+
+	var fs []*Fault
+	for i := range f.plan {
+		fl := &f.plan[i]
+		if fl.Op != op {
+			continue
+		}
+		if (fl.MatchByte && (op == FaultRead || fl.Byte == b)) || (!fl.MatchByte && fl.Index == idx) {
+			fs = append(fs, fl)
+		}
+	}
+
+	for _, fl := range fs {
+		if fl.Delay > 0 && !(op == FaultRead && fl.MatchByte) {
+			sleep(fl.Delay)
+		}
+	}
+	return fs
+}
+
+// apply returns err altered by the faults fs.
+func apply(fs []*Fault, err error) error {
+	for _, fl := range fs {
+		if fl.Err != nil {
+			err = fl.Err
+		}
+	}
+	return err
+}
+
+func (f *faultInjector) Start() error {
+	fs := f.faults(FaultStart, 0)
+	return apply(fs, f.m.Start())
+}
+
+func (f *faultInjector) Stop() error {
+	fs := f.faults(FaultStop, 0)
+	return apply(fs, f.m.Stop())
+}
+
+func (f *faultInjector) WriteByte(b byte) error {
+	fs := f.faults(FaultWrite, b)
+	return apply(fs, f.m.WriteByte(b))
+}
+
+func (f *faultInjector) ReadByte(ack bool) (byte, error) {
+	fs := f.faults(FaultRead, 0)
+	b, err := f.m.ReadByte(ack)
+
+	// faults matching the byte read are only known now
+	var matched []*Fault
+	for _, fl := range fs {
+		if !fl.MatchByte || fl.Byte == b {
+			matched = append(matched, fl)
+		}
+	}
+
+	for _, fl := range matched {
+		b ^= fl.Corrupt
+	}
+	return b, apply(matched, err)
+}
+
+// SupportsRepeatedStart implements NoRepeatedStart on behalf of the
+// underlying master.
+func (f *faultInjector) SupportsRepeatedStart() bool {
+	return supportsRepeatedStart(f.m)
+}
@@ -0,0 +1,49 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFaultInjector(t *testing.T) {
+	delays := fakeSleep(t)
+	errTimeout := errors.New("timeout")
+
+	md := newmemdev256(Addr7(0x50))
+	copy(md.mem[0x10:], []byte{1, 2, 3, 4})
+	m := NewFaultInjector(md, []Fault{
+		{Op: FaultWrite, Index: 4, Err: NACKReceived},              // data byte of the second transaction
+		{Op: FaultRead, Index: 1, Corrupt: 0x80},                   // second byte read
+		{Op: FaultRead, MatchByte: true, Byte: 4, Err: errTimeout}, // every read of 4
+		{Op: FaultStart, Index: 0, Delay: time.Millisecond},        // first start
+	})
+	tr := NewTransact8x8(m)
+
+	r := make([]byte, 3)
+	if _, nr, err := tr.Transact8x8(Addr7(0x50), 0x10, nil, r); nr != 3 || err != nil {
+		t.Fatalf("expected to read 3 bytes, got %d, %v", nr, err)
+	}
+	if string(r) != "\x01\x82\x03" {
+		t.Fatalf("expected the second byte to be corrupted, got % x", r)
+	}
+	if len(*delays) != 1 || (*delays)[0] != time.Millisecond {
+		t.Fatalf("expected a delay before the first start, got %v", *delays)
+	}
+
+	// writes: 0xa0 0x10 0xa1 above, 0xa0 0x20 0xff here
+	if nw, _, err := tr.Transact8x8(Addr7(0x50), 0x20, []byte{0xff}, nil); nw != 0 || err != NACKReceived {
+		t.Fatalf("expected the data byte to be NACKed, got %d, %v", nw, err)
+	}
+
+	if _, nr, err := tr.Transact8x8(Addr7(0x50), 0x13, nil, r[:1]); nr != 0 || err != errTimeout {
+		t.Fatalf("expected the read of 4 to time out, got %d, %v", nr, err)
+	}
+	if _, nr, err := tr.Transact8x8(Addr7(0x50), 0x12, nil, r[:1]); nr != 1 || err != nil || r[0] != 3 {
+		t.Fatalf("expected an undisturbed read, got %d, %v, %#x", nr, err, r[0])
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"time"
+)
+
+type gatedMaster struct {
+	m      I2CMaster
+	enable func(bool) error
+	settle time.Duration
+	open   bool // whether a transaction is in progress
+}
+
+// NewGatedMaster returns an I2CMaster which enables the bus by calling
+// enable(true) before each transaction on m and disables it by calling
+// enable(false) after the transaction, e.g. to switch the bus power.
+// After enabling, settle is waited for before the start condition is
+// sent. A transaction lasts from a start condition on an idle bus to
+// the next stop condition, so repeated starts do not touch the gate.
+// Transactions whose phases are separated by a stop and a start
+// condition, c.f. NoRepeatedStart, disable and enable the bus between
+// the phases.
+func NewGatedMaster(m I2CMaster, enable func(bool) error, settle time.Duration) I2CMaster {
+	return &gatedMaster{m: m, enable: enable, settle: settle}
+}
+
+func (g *gatedMaster) Start() error {
+	if g.open {
+		return g.m.Start()
+	}
+
+	if err := g.enable(true); err != nil {
+		return err
+	}
+	if g.settle > 0 {
+		sleep(g.settle)
+	}
+
+	if err := g.m.Start(); err != nil {
+		g.enable(false)
+		return err
+	}
+	g.open = true
+	return nil
+}
+
+func (g *gatedMaster) Stop() error {
+	err := g.m.Stop()
+	if g.open {
+		g.open = false
+		if eerr := g.enable(false); err == nil {
+			err = eerr
+		}
+	}
+	return err
+}
+
+func (g *gatedMaster) ReadByte(ack bool) (byte, error) {
+	return g.m.ReadByte(ack)
+}
+
+func (g *gatedMaster) WriteByte(b byte) error {
+	return g.m.WriteByte(b)
+}
+
+// SupportsRepeatedStart implements NoRepeatedStart on behalf of the
+// underlying master.
+func (g *gatedMaster) SupportsRepeatedStart() bool {
+	return supportsRepeatedStart(g.m)
+}
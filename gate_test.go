@@ -0,0 +1,47 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// gateLogger logs bus conditions and gate switching.
+type gateLogger struct {
+	scriptedMaster
+	log []string
+}
+
+func (g *gateLogger) Start() error { g.log = append(g.log, "start"); return nil }
+func (g *gateLogger) Stop() error  { g.log = append(g.log, "stop"); return nil }
+
+func (g *gateLogger) enable(on bool) error {
+	g.log = append(g.log, fmt.Sprintf("enable %v", on))
+	return nil
+}
+
+func TestGatedMaster(t *testing.T) {
+	delays := fakeSleep(t)
+
+	l := &gateLogger{scriptedMaster: scriptedMaster{rd: []byte{1, 2}}}
+	m := NewGatedMaster(l, l.enable, time.Millisecond)
+	tr := NewTransact8x8(m)
+
+	tr.Transact8x8(Addr7(0x50), 0, nil, make([]byte, 1))
+	tr.Transact8x8(Addr7(0x50), 0, []byte{1}, nil)
+
+	exp := []string{
+		"enable true", "start", "start", "stop", "enable false", // repeated start keeps the gate
+		"enable true", "start", "stop", "enable false",
+	}
+	if fmt.Sprint(l.log) != fmt.Sprint(exp) {
+		t.Fatalf("expected %v, got %v", exp, l.log)
+	}
+	if len(*delays) != 2 || (*delays)[0] != time.Millisecond {
+		t.Fatalf("expected a settling delay per transaction, got %v", *delays)
+	}
+}
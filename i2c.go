@@ -31,3 +31,41 @@ type I2CMaster interface {
 	// device does not ACK, it returns NACKReceived.
 	WriteByte(b byte) error
 }
+
+// NoRepeatedStart is implemented by I2C masters which may not be able
+// to send a repeated start condition. If SupportsRepeatedStart returns
+// false, the transactions in this package end the write phase with a
+// stop condition and start the read phase with a new start condition
+// instead of a repeated start.
+type NoRepeatedStart interface {
+	SupportsRepeatedStart() bool
+}
+
+// supportsRepeatedStart reports whether m can send repeated start
+// conditions. Masters are assumed to support them unless they say
+// otherwise via NoRepeatedStart.
+func supportsRepeatedStart(m I2CMaster) bool {
+	if n, ok := m.(NoRepeatedStart); ok {
+		return n.SupportsRepeatedStart()
+	}
+	return true
+}
+
+// ClockReporter is implemented by I2C masters which know the bus clock
+// frequency they actually achieve, e.g. software masters which time a
+// calibration burst. The actual frequency of such masters depends on
+// the speed of the host and may differ considerably from the nominal
+// setting.
+type ClockReporter interface {
+	// ClockHz returns the measured bus clock frequency in Hz.
+	ClockHz() float64
+}
+
+// MeasuredClock returns the bus clock frequency reported by m. The
+// boolean result is false if m does not implement ClockReporter.
+func MeasuredClock(m I2CMaster) (float64, bool) {
+	if c, ok := m.(ClockReporter); ok {
+		return c.ClockHz(), true
+	}
+	return 0, false
+}
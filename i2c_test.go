@@ -0,0 +1,26 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"testing"
+)
+
+// clockMaster is an I2CMaster which reports a measured clock of hz.
+type clockMaster struct {
+	scriptedMaster
+	hz float64
+}
+
+func (c *clockMaster) ClockHz() float64 { return c.hz }
+
+func TestMeasuredClock(t *testing.T) {
+	if hz, ok := MeasuredClock(&clockMaster{hz: 87300}); hz != 87300 || !ok {
+		t.Errorf("expected the reported clock of 87300 Hz, got %v, %v", hz, ok)
+	}
+	if hz, ok := MeasuredClock(&scriptedMaster{}); hz != 0 || ok {
+		t.Errorf("expected no clock from a master which does not report one, got %v, %v", hz, ok)
+	}
+}
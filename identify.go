@@ -0,0 +1,191 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"fmt"
+)
+
+// Confidence rates a DeviceGuess.
+type Confidence int
+
+const (
+	ConfidenceNone Confidence = iota // the device responded, but matches no heuristic
+	ConfidenceLow                    // the device's address is typical for the device type
+	ConfidenceHigh                   // the device type was confirmed by reading the device
+)
+
+// DeviceGuess is the device type Identify guesses for a device which
+// responded on the bus.
+type DeviceGuess struct {
+	Addr       Addr7
+	Device     string // empty for ConfidenceNone
+	Confidence Confidence
+}
+
+// Heuristic describes how to recognize a device type.
+type Heuristic struct {
+	First, Last Addr7 // range of addresses the device type may use
+	Device      string
+
+	// Check, if not nil, reads from the device at addr and reports
+	// whether it is of the device type, e.g. by reading an ID register.
+	// It may set register pointers but must not write to registers.
+	// Without Check, devices are guessed by address alone.
+	Check func(tr Transactor8x8, addr Addr7) (bool, error)
+}
+
+// DefaultHeuristics are the heuristics used by Identify.
+var DefaultHeuristics = []Heuristic{
+	{First: 0x3c, Last: 0x3d, Device: "SSD1306 OLED controller"},
+	{First: 0x48, Last: 0x4f, Device: "LM75 temperature sensor"},
+	{First: 0x50, Last: 0x57, Device: "24Cxx EEPROM"},
+	{First: 0x68, Last: 0x69, Device: "MPU-6050 motion sensor", Check: checkWhoAmI(0x75, 0x7e, 0x68)},
+	{First: 0x68, Last: 0x68, Device: "DS1307/DS3231 real-time clock"},
+	{First: 0x70, Last: 0x77, Device: "TCA9548A I2C multiplexer"},
+}
+
+// checkWhoAmI returns a Heuristic check which reads register reg and
+// compares it, masked with mask, to id.
+func checkWhoAmI(reg, mask, id uint8) func(Transactor8x8, Addr7) (bool, error) {
+	return func(tr Transactor8x8, addr Addr7) (bool, error) {
+		var b [1]byte
+		if _, _, err := tr.Transact8x8(addr, reg, nil, b[:]); err != nil {
+			if err == NoSuchDevice || err == NACKReceived {
+				return false, nil
+			}
+			return false, err
+		}
+		return b[0]&mask == id, nil
+	}
+}
+
+// Scan probes the 7 bit addresses 0x08 to 0x77, i.e. all but the
+// reserved ones, by addressing them for writing without writing any
+// data, and returns the addresses which were ACKed in ascending order.
+// Addresses which NACK are skipped. Other errors abort the scan and are
+// returned along with the addresses found so far.
+func Scan(m I2CMaster) ([]Addr7, error) {
+	var found []Addr7
+	for a := Addr7(0x08); a <= 0x77; a++ {
+		ok, err := probe(m, a)
+		if err != nil {
+			return found, err
+		}
+		if ok {
+			found = append(found, a)
+		}
+	}
+	return found, nil
+}
+
+// Identify scans the bus for devices and guesses their types using
+// DefaultHeuristics, c.f. IdentifyWith.
+func Identify(m I2CMaster) ([]DeviceGuess, error) {
+	return IdentifyWith(m, DefaultHeuristics)
+}
+
+// IdentifyWith scans the bus like Scan. For each address which is
+// ACKed, every heuristic whose address range contains the address and
+// whose check, if any, succeeds yields a guess, in the order of hs.
+// Addresses matching no heuristic yield a guess with ConfidenceNone.
+// The guesses are returned in ascending order of addresses. An error of
+// the scan is returned without any guesses, an error of a check along
+// with the guesses made so far.
+func IdentifyWith(m I2CMaster, hs []Heuristic) ([]DeviceGuess, error) {
+	found, err := Scan(m)
+	if err != nil {
+		return nil, err
+	}
+
+	tr := NewTransact8x8(m)
+	var guesses []DeviceGuess
+	for _, a := range found {
+		matched := false
+		for _, h := range hs {
+			if a < h.First || a > h.Last {
+				continue
+			}
+
+			c := ConfidenceLow
+			if h.Check != nil {
+				ok, err := h.Check(tr, a)
+				if err != nil {
+					return guesses, err
+				}
+				if !ok {
+					continue
+				}
+				c = ConfidenceHigh
+			}
+			guesses = append(guesses, DeviceGuess{a, h.Device, c})
+			matched = true
+		}
+
+		if !matched {
+			guesses = append(guesses, DeviceGuess{Addr: a})
+		}
+	}
+
+	return guesses, nil
+}
+
+// deviceIDAddr is the reserved address of the device ID command.
+const deviceIDAddr = 0x7c
+
+// ReadDeviceID reads the device ID defined by the I2C specification
+// from the device at target. The transaction is carried out as follows:
+//
+//	[S] [0xf8] A [target<<1] A [S] [0xf9] ID[0] [A] ID[1] [A] ID[2] [N] [P]
+//
+// The 24 bit ID holds the 12 bit manufacturer, the 9 bit part number
+// and the 3 bit revision. If the device ID address or the target NACK,
+// ErrDeviceIDUnsupported is returned. Many devices do not implement
+// device IDs.
+func ReadDeviceID(m I2CMaster, target Addr7) (manufacturer uint16, part uint16, revision uint8, err error) {
+	if target > 0x7f {
+		return 0, 0, 0, fmt.Errorf("ReadDeviceID: address %#02x exceeds 7 bits", uint8(target))
+	}
+
+	if err := m.Start(); err != nil {
+		return 0, 0, 0, err
+	}
+
+	var id [3]byte
+	err = func() error {
+		for _, b := range []byte{deviceIDAddr << 1, uint8(target) << 1} {
+			if err := m.WriteByte(b); err != nil {
+				if err == NACKReceived {
+					return ErrDeviceIDUnsupported
+				}
+				return err
+			}
+		}
+
+		if err := m.Start(); err != nil {
+			return fmt.Errorf("%w: %w", ErrReadPhaseStartFailed, err)
+		}
+		if err := m.WriteByte(deviceIDAddr<<1 | 0x01); err != nil {
+			if err == NACKReceived {
+				return ErrDeviceIDUnsupported
+			}
+			return err
+		}
+
+		_, err := ReadBlock(m, id[:], false)
+		return err
+	}()
+
+	if err != nil {
+		m.Stop()
+		return 0, 0, 0, err
+	}
+	if err := m.Stop(); err != nil {
+		return 0, 0, 0, err
+	}
+
+	v := uint32(id[0])<<16 | uint32(id[1])<<8 | uint32(id[2])
+	return uint16(v >> 12), uint16(v>>3) & 0x1ff, uint8(v) & 0x07, nil
+}
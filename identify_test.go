@@ -0,0 +1,141 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// memBus is a bus of memdev256s. Addresses without a device NACK.
+type memBus struct {
+	devs []*memdev256
+	cur  *memdev256 // device addressed in the current transaction
+}
+
+func (b *memBus) Start() error {
+	if b.cur != nil {
+		return b.cur.Start()
+	}
+	return nil
+}
+
+func (b *memBus) Stop() error {
+	if b.cur == nil {
+		return nil
+	}
+	err := b.cur.Stop()
+	b.cur = nil
+	return err
+}
+
+func (b *memBus) WriteByte(v byte) error {
+	if b.cur == nil {
+		for _, d := range b.devs {
+			if uint8(d.addr) == v>>1 {
+				b.cur = d
+				d.Start()
+			}
+		}
+		if b.cur == nil {
+			return NACKReceived
+		}
+	}
+	return b.cur.WriteByte(v)
+}
+
+func (b *memBus) ReadByte(ack bool) (byte, error) {
+	return b.cur.ReadByte(ack)
+}
+
+func TestIdentify(t *testing.T) {
+	mpu := newmemdev256(Addr7(0x68))
+	mpu.mem[0x75] = 0x68
+	bus := &memBus{devs: []*memdev256{
+		newmemdev256(Addr7(0x20)),
+		newmemdev256(Addr7(0x50)),
+		mpu,
+		newmemdev256(Addr7(0x69)), // no MPU-6050 ID
+	}}
+
+	guesses, err := Identify(NewBusSanityMaster(bus))
+	if err != nil {
+		t.Fatalf("Identify failed: %v", err)
+	}
+
+	exp := []DeviceGuess{
+		{0x20, "", ConfidenceNone},
+		{0x50, "24Cxx EEPROM", ConfidenceLow},
+		{0x68, "MPU-6050 motion sensor", ConfidenceHigh},
+		{0x68, "DS1307/DS3231 real-time clock", ConfidenceLow},
+		{0x69, "", ConfidenceNone},
+	}
+	if fmt.Sprint(guesses) != fmt.Sprint(exp) {
+		t.Fatalf("expected guesses %v, got %v", exp, guesses)
+	}
+
+	for _, d := range bus.devs {
+		for i, v := range d.mem {
+			if i != 0x75 && v != 0 {
+				t.Fatalf("Identify wrote to register %#02x of device %v", i, d.addr)
+			}
+		}
+	}
+}
+
+func TestReadDeviceID(t *testing.T) {
+	// manufacturer 0x123, part 0x0a5, revision 5
+	m := NewRecordingMaster(&scriptedMaster{rd: []byte{0x12, 0x35, 0x2d}})
+	mf, part, rev, err := ReadDeviceID(m, Addr7(0x50))
+	if mf != 0x123 || part != 0x0a5 || rev != 5 || err != nil {
+		t.Fatalf("expected 0x123, 0x0a5, 5, got %#x, %#x, %d, %v", mf, part, rev, err)
+	}
+	checkLog(t, recorded(m), []i2cItem{{t_START, 0, false, nil},
+		{t_WRITE, 0xf8, false, nil},
+		{t_WRITE, 0xa0, false, nil}, // target
+		{t_START, 0, false, nil},
+		{t_WRITE, 0xf9, false, nil},
+		{t_READ, 0x12, true, nil},
+		{t_READ, 0x35, true, nil},
+		{t_READ, 0x2d, false, nil},
+		{t_STOP, 0, false, nil},
+	})
+
+	if _, _, _, err := ReadDeviceID(&alwaysNACK{}, Addr7(0x50)); err != ErrDeviceIDUnsupported {
+		t.Fatalf("expected ErrDeviceIDUnsupported, got %v", err)
+	}
+
+	// addresses exceeding 7 bits are rejected without bus activity
+	m = NewRecordingMaster(&scriptedMaster{})
+	if _, _, _, err := ReadDeviceID(m, Addr7(0xd0)); err == nil {
+		t.Fatalf("ReadDeviceID accepted an 8 bit address")
+	}
+	if log := recorded(m); len(log) != 0 {
+		t.Fatalf("expected no bus activity, got %v", log)
+	}
+}
+
+func TestScan(t *testing.T) {
+	bus := &memBus{devs: []*memdev256{
+		newmemdev256(Addr7(0x68)),
+		newmemdev256(Addr7(0x05)), // reserved
+		newmemdev256(Addr7(0x20)),
+		newmemdev256(Addr7(0x7a)), // reserved
+	}}
+
+	found, err := Scan(NewBusSanityMaster(bus))
+	if err != nil || fmt.Sprint(found) != fmt.Sprint([]Addr7{0x20, 0x68}) {
+		t.Fatalf("expected devices at 0x20 and 0x68, got %v, %v", found, err)
+	}
+
+	// a bus error while probing 0x38 aborts the scan
+	busErr := errors.New("bus error")
+	m := NewFaultInjector(NewBusSanityMaster(bus), []Fault{{Op: FaultWrite, Index: 0x38 - 0x08, Err: busErr}})
+	found, err = Scan(m)
+	if err != busErr || fmt.Sprint(found) != fmt.Sprint([]Addr7{0x20}) {
+		t.Fatalf("expected the device at 0x20 and the bus error, got %v, %v", found, err)
+	}
+}
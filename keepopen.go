@@ -0,0 +1,63 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"errors"
+)
+
+// OpenTransaction is a write transaction which has not been ended by
+// a stop condition, so the bus is still owned by the master. It is
+// created by KeepOpen8x8. The caller is obliged to end it by calling
+// Stop, and as long as it is not stopped, no other transactions may be
+// carried out on the bus. A forgotten stop leaves the bus hanging.
+type OpenTransaction struct {
+	m       I2CMaster
+	stopped bool
+}
+
+var errTransactionStopped = errors.New("OpenTransaction: transaction already stopped")
+
+// KeepOpen8x8 carries out the write phase of an 8x8 transaction as
+// described at Transactor8x8, but does not send the stop condition at
+// its end. This allows writing the same data to several devices in one
+// bus transaction by readdressing with a repeated start via the
+// returned OpenTransaction's Write method. It returns the number of
+// bytes of w written. If an error occurs, a stop condition is sent and
+// no OpenTransaction is returned.
+func KeepOpen8x8(m I2CMaster, addr Addr, regaddr uint8, w []byte) (*OpenTransaction, int, error) {
+	o := &OpenTransaction{m: m}
+	n, err := o.Write(addr, regaddr, w)
+	if err != nil {
+		return nil, n, err
+	}
+	return o, n, nil
+}
+
+// Write sends a repeated start condition and writes w to register
+// regaddr of the device at addr, leaving the transaction open. It
+// returns the number of bytes of w written. If an error occurs, a stop
+// condition is sent and the transaction is finished.
+func (o *OpenTransaction) Write(addr Addr, regaddr uint8, w []byte) (int, error) {
+	if o.stopped {
+		return 0, errTransactionStopped
+	}
+
+	ptr := [1]byte{regaddr}
+	res := transact(o.m, &Transact8x8Options{keepOpen: true}, addr, ptr[:], w, nil)
+	if res.Err != nil {
+		o.stopped = true
+	}
+	return res.BytesWritten, res.Err
+}
+
+// Stop ends the transaction by sending a stop condition.
+func (o *OpenTransaction) Stop() error {
+	if o.stopped {
+		return errTransactionStopped
+	}
+	o.stopped = true
+	return o.m.Stop()
+}
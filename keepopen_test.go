@@ -0,0 +1,48 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"testing"
+)
+
+func TestKeepOpen8x8(t *testing.T) {
+	m := NewRecordingMaster(&scriptedMaster{})
+
+	o, n, err := KeepOpen8x8(m, Addr7(0x50), 0x10, []byte{0xab})
+	if n != 1 || err != nil {
+		t.Fatalf("expected to write 1 byte, got %d, %v", n, err)
+	}
+	if n, err := o.Write(Addr7(0x51), 0x10, []byte{0xab}); n != 1 || err != nil {
+		t.Fatalf("expected to write 1 byte, got %d, %v", n, err)
+	}
+	if err := o.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if err := o.Stop(); err == nil {
+		t.Fatalf("second Stop did not fail")
+	}
+
+	checkLog(t, recorded(m), []i2cItem{{t_START, 0, false, nil},
+		{t_WRITE, 0xa0, false, nil},
+		{t_WRITE, 0x10, false, nil},
+		{t_WRITE, 0xab, false, nil},
+		{t_START, 0x00, false, nil}, // repeated start
+		{t_WRITE, 0xa2, false, nil},
+		{t_WRITE, 0x10, false, nil},
+		{t_WRITE, 0xab, false, nil},
+		{t_STOP, 0x00, false, nil},
+	})
+
+	// on errors, the bus is released
+	m = NewRecordingMaster(&alwaysNACK{})
+	if o, _, err := KeepOpen8x8(m, Addr7(0x50), 0x10, nil); o != nil || err != NoSuchDevice {
+		t.Fatalf("expected NoSuchDevice and no open transaction, got %v, %v", o, err)
+	}
+	log := recorded(m)
+	if last := log[len(log)-1]; last.typ != t_STOP {
+		t.Fatalf("expected the failed transaction to be stopped, last item is %v", last)
+	}
+}
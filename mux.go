@@ -0,0 +1,146 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Mux is a TCA9548A style I2C multiplexer. It hands out I2CMasters for
+// its channels, which share the multiplexer's state: the lock held from
+// the start to the stop of a transaction and the channel selected last.
+type Mux struct {
+	m    I2CMaster
+	addr Addr
+
+	mu       sync.Mutex // held from the start to the stop of a transaction
+	selected int        // currently selected channel, -1 if unknown
+}
+
+// NewMux returns the multiplexer at muxAddr on m's bus. The I2CMasters
+// returned by Channel serialize their transactions, so they may be used
+// concurrently. Each of them may only be used by one goroutine at a
+// time, though: a start condition on a master whose transaction is in
+// progress is taken as a repeated start of that transaction. Goroutines
+// sharing a channel obtain a master each from Channel. No other
+// transactions may be carried out on m directly meanwhile, and all
+// channels of the multiplexer need to be accessed through the same Mux.
+func NewMux(m I2CMaster, muxAddr Addr) *Mux {
+	return &Mux{m: m, addr: muxAddr, selected: -1}
+}
+
+// Channel returns a new I2CMaster which accesses the devices on channel
+// channel of the multiplexer. At the start of a transaction, the
+// channel is selected by writing its bit mask to the multiplexer in a
+// separate transaction, unless it is known to be selected already.
+// Repeated starts within a transaction do not reselect the channel. The
+// returned I2CMaster implements MuxReselecter. Channel panics if
+// channel is not in the range 0 to 7.
+func (x *Mux) Channel(channel uint8) I2CMaster {
+	if channel > 7 {
+		panic(fmt.Sprintf("Mux.Channel: invalid channel %d", channel))
+	}
+	return &muxMaster{mux: x, channel: channel}
+}
+
+// ForceReselect makes the next transaction on any channel select its
+// channel, even if it was selected last. It needs to be called if the
+// multiplexer may have lost its state, e.g. after it was reset, as
+// transactions would otherwise be carried out on whatever channel it
+// came up with. ForceReselect may not be called within a transaction.
+func (x *Mux) ForceReselect() {
+	x.mu.Lock()
+	x.selected = -1
+	x.mu.Unlock()
+}
+
+type muxMaster struct {
+	mux     *Mux
+	channel uint8
+
+	// open tells whether a transaction is in progress. It is not
+	// synchronized, as a master is used by one goroutine at a time.
+	open bool
+}
+
+// NewMuxMaster returns an I2CMaster for channel channel of a new Mux at
+// muxAddr on m's bus, c.f. NewMux and Mux.Channel. It is meant for a
+// single channel used by one goroutine at a time. Channels used
+// concurrently, including the same channel used by several goroutines,
+// need to be obtained from one Mux, as separate Muxes neither serialize
+// their transactions nor share the selected channel.
+func NewMuxMaster(m I2CMaster, muxAddr Addr, channel uint8) I2CMaster {
+	return NewMux(m, muxAddr).Channel(channel)
+}
+
+// MuxReselecter is implemented by the I2CMasters returned by
+// Mux.Channel. ForceReselect calls ForceReselect of their Mux.
+type MuxReselecter interface {
+	ForceReselect()
+}
+
+// selectChannel writes the channel's bit mask to the multiplexer if the
+// channel is not known to be selected. The mux needs to be locked.
+func (x *muxMaster) selectChannel() error {
+	mux := x.mux
+	if mux.selected == int(x.channel) {
+		return nil
+	}
+
+	res := transact(mux.m, &Transact8x8Options{}, mux.addr, []byte{1 << x.channel}, nil, nil)
+	if res.Err != nil {
+		// the multiplexer may or may not have taken the new selection
+		mux.selected = -1
+		return res.Err
+	}
+	mux.selected = int(x.channel)
+	return nil
+}
+
+func (x *muxMaster) ForceReselect() {
+	x.mux.ForceReselect()
+}
+
+func (x *muxMaster) Start() error {
+	if x.open {
+		return x.mux.m.Start()
+	}
+
+	x.mux.mu.Lock()
+	if err := x.selectChannel(); err != nil {
+		x.mux.mu.Unlock()
+		return err
+	}
+	if err := x.mux.m.Start(); err != nil {
+		x.mux.mu.Unlock()
+		return err
+	}
+	x.open = true
+	return nil
+}
+
+func (x *muxMaster) Stop() error {
+	err := x.mux.m.Stop()
+	if x.open {
+		x.open = false
+		x.mux.mu.Unlock()
+	}
+	return err
+}
+
+func (x *muxMaster) ReadByte(ack bool) (byte, error) {
+	return x.mux.m.ReadByte(ack)
+}
+
+func (x *muxMaster) WriteByte(b byte) error {
+	return x.mux.m.WriteByte(b)
+}
+
+// SupportsRepeatedStart implements NoRepeatedStart on behalf of the
+// underlying master.
+func (x *muxMaster) SupportsRepeatedStart() bool {
+	return supportsRepeatedStart(x.mux.m)
+}
@@ -0,0 +1,168 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestMuxMaster(t *testing.T) {
+	m := NewRecordingMaster(&scriptedMaster{rd: []byte{0x80}})
+	x := NewMuxMaster(m, Addr7(0x70), 2)
+
+	if _, _, err := NewTransact8x8(x).Transact8x8(Addr7(0x50), 0x10, nil, make([]byte, 1)); err != nil {
+		t.Fatalf("transaction failed: %v", err)
+	}
+	checkLog(t, recorded(m), []i2cItem{{t_START, 0, false, nil},
+		{t_WRITE, 0xe0, false, nil}, // mux address
+		{t_WRITE, 0x04, false, nil}, // channel 2
+		{t_STOP, 0x00, false, nil},
+		{t_START, 0x00, false, nil},
+		{t_WRITE, 0xa0, false, nil},
+		{t_WRITE, 0x10, false, nil},
+		{t_START, 0x00, false, nil}, // repeated start, no reselection
+		{t_WRITE, 0xa1, false, nil},
+		{t_READ, 0x80, false, nil},
+		{t_STOP, 0x00, false, nil},
+	})
+
+	// a failing selection fails the transaction
+	x = NewMuxMaster(&alwaysNACK{}, Addr7(0x70), 0)
+	if _, _, err := NewTransact8x8(x).Transact8x8(Addr7(0x50), 0, nil, nil); err != NoSuchDevice {
+		t.Fatalf("expected NoSuchDevice, got %v", err)
+	}
+}
+
+func TestMuxMasterConcurrent(t *testing.T) {
+	m := NewRecordingMaster(&scriptedMaster{})
+	mux := NewMux(m, Addr7(0x70))
+
+	var wg sync.WaitGroup
+	for ch := uint8(0); ch < 4; ch++ {
+		wg.Add(1)
+		go func(ch uint8) {
+			defer wg.Done()
+			tr := NewTransact8x8(mux.Channel(ch))
+			for i := 0; i < 20; i++ {
+				tr.Transact8x8(Addr7(0x50), ch, nil, nil)
+			}
+		}(ch)
+	}
+	wg.Wait()
+
+	// every transaction is carried out on the channel selected last
+	sel := -1
+	log := recorded(m)
+	for i := 0; i+4 <= len(log); i += 4 {
+		if log[i+1].b == 0xe0 {
+			sel = int(log[i+2].b)
+			continue
+		}
+		if reg := int(log[i+2].b); sel != 1<<uint(reg) {
+			t.Fatalf("transaction at log item %d with register %d carried out with selection %#02x", i, reg, sel)
+		}
+	}
+}
+
+func TestMuxMasterSharedChannel(t *testing.T) {
+	m := NewRecordingMaster(&scriptedMaster{})
+	mux := NewMux(m, Addr7(0x70))
+
+	// the goroutines obtain a master each for the same channel
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			tr := NewTransact8x8(mux.Channel(3))
+			for i := 0; i < 20; i++ {
+				tr.Transact8x8(Addr7(0x50), uint8(g), []byte{uint8(g)}, nil)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	// transactions are not interleaved, and the channel is only
+	// selected once
+	log := recorded(m)
+	checkLog(t, log[:4], []i2cItem{{t_START, 0, false, nil},
+		{t_WRITE, 0xe0, false, nil},
+		{t_WRITE, 0x08, false, nil},
+		{t_STOP, 0, false, nil},
+	})
+	log = log[4:]
+	if len(log) != 4*20*5 {
+		t.Fatalf("expected %d transactions of 5 items, got %d items", 4*20, len(log))
+	}
+	for i := 0; i < len(log); i += 5 {
+		tx := log[i : i+5]
+		if tx[0].typ != t_START || tx[1].b != 0xa0 || tx[2].b != tx[3].b || tx[4].typ != t_STOP {
+			t.Fatalf("transaction at log item %d is interleaved: %v", i, tx)
+		}
+	}
+}
+
+func TestMuxMasterCache(t *testing.T) {
+	m := NewRecordingMaster(&scriptedMaster{})
+	mux := NewMux(m, Addr7(0x71))
+	x1, x2 := mux.Channel(1), mux.Channel(2)
+
+	countSelections := func() int {
+		n := 0
+		for _, e := range recorded(m) {
+			if e.typ == t_WRITE && e.b == 0xe2 {
+				n++
+			}
+		}
+		return n
+	}
+
+	tr1, tr2 := NewTransact8x8(x1), NewTransact8x8(x2)
+	tr1.Transact8x8(Addr7(0x50), 0, nil, nil)
+	tr1.Transact8x8(Addr7(0x50), 0, nil, nil)
+	if n := countSelections(); n != 1 {
+		t.Fatalf("expected 1 selection for two transactions on one channel, got %d", n)
+	}
+
+	tr2.Transact8x8(Addr7(0x50), 0, nil, nil)
+	tr1.Transact8x8(Addr7(0x50), 0, nil, nil)
+	if n := countSelections(); n != 3 {
+		t.Fatalf("expected a selection on every change of channels, got %d selections", n)
+	}
+
+	x1.(MuxReselecter).ForceReselect()
+	tr1.Transact8x8(Addr7(0x50), 0, nil, nil)
+	if n := countSelections(); n != 4 {
+		t.Fatalf("expected a selection after ForceReselect, got %d selections", n)
+	}
+	mux.ForceReselect()
+	tr2.Transact8x8(Addr7(0x50), 0, nil, nil)
+	tr2.Transact8x8(Addr7(0x50), 0, nil, nil)
+	if n := countSelections(); n != 5 {
+		t.Fatalf("expected a selection after Mux.ForceReselect, got %d selections", n)
+	}
+
+	// another Mux on the same master has a cache of its own
+	other := NewTransact8x8(NewMux(m, Addr7(0x71)).Channel(2))
+	other.Transact8x8(Addr7(0x50), 0, nil, nil)
+	tr2.Transact8x8(Addr7(0x50), 0, nil, nil)
+	if n := countSelections(); n != 6 {
+		t.Fatalf("expected the other Mux to select and the first one to keep its cache, got %d selections", n)
+	}
+}
+
+// sliceMaster is not comparable.
+type sliceMaster struct {
+	*scriptedMaster
+	_ []int
+}
+
+func TestMuxNonComparableMaster(t *testing.T) {
+	x := NewMux(sliceMaster{scriptedMaster: &scriptedMaster{}}, Addr7(0x70)).Channel(0)
+	if _, _, err := NewTransact8x8(x).Transact8x8(Addr7(0x50), 0, nil, nil); err != nil {
+		t.Fatalf("transaction failed: %v", err)
+	}
+}
@@ -0,0 +1,282 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// TransactorOption configures the transactors returned by NewTransactor.
+type TransactorOption func(*transactorConfig)
+
+type transactorConfig struct {
+	opts     Transact8x8Options
+	lowlevel bool // whether the I2CMaster interface needs to be used
+	retries  int
+
+	maxReadLen int
+	maxNACKs   int
+}
+
+// WithRetry makes the transactors repeat failed transactions up to
+// retries times. Transactions are repeated as a whole, so data written
+// by a failed attempt is written again.
+func WithRetry(retries int) TransactorOption {
+	return func(c *transactorConfig) {
+		c.retries = retries
+	}
+}
+
+// WithMaxConsecutiveNACKs makes transactions fail with ErrBusUnhealthy
+// while more than n consecutive transactions failed with a NACK, so
+// that applications polling or retrying can tell a device which never
+// responds from a busy one. Transactions are still carried out, and the
+// first successful one resets the count. Failures other than NACKs do
+// not change the count. With WithRetry, every attempt counts.
+func WithMaxConsecutiveNACKs(n int) TransactorOption {
+	return func(c *transactorConfig) {
+		c.maxNACKs = n
+	}
+}
+
+// WithMaxReadLen makes the transactors read at most n bytes per
+// transaction, for devices which return only a limited number of bytes
+// per read. Longer reads are split into several transactions, each
+// setting the register address to the position following the bytes
+// read so far. The bytes of w are written by the first transaction
+// only, and the reads start at regaddr + len(w), as the device's
+// register pointer advances past the bytes written.
+func WithMaxReadLen(n int) TransactorOption {
+	return func(c *transactorConfig) {
+		c.maxReadLen = n
+	}
+}
+
+// WithInterByteDelay makes the transactors wait for d after each byte
+// transferred, c.f. Transact8x8Options.InterByteDelay.
+func WithInterByteDelay(d time.Duration) TransactorOption {
+	return func(c *transactorConfig) {
+		c.opts.InterByteDelay = d
+		c.lowlevel = true
+	}
+}
+
+// WithNoRepeatedStart makes the transactors separate the write and the
+// read phase of transactions by a stop and a start condition, c.f.
+// Transact8x8Options.NoRepeatedStart.
+func WithNoRepeatedStart() TransactorOption {
+	return func(c *transactorConfig) {
+		c.opts.NoRepeatedStart = true
+		c.lowlevel = true
+	}
+}
+
+// WithDummyFirstRead makes the transactors discard the first byte read,
+// c.f. Transact8x8Options.DummyFirstRead.
+func WithDummyFirstRead() TransactorOption {
+	return func(c *transactorConfig) {
+		c.opts.DummyFirstRead = true
+		c.lowlevel = true
+	}
+}
+
+// WithReadPhaseDelay makes the transactors wait for d between the write
+// and the read phase of transactions, c.f.
+// Transact8x8Options.ReadPhaseDelay.
+func WithReadPhaseDelay(d time.Duration) TransactorOption {
+	return func(c *transactorConfig) {
+		c.opts.ReadPhaseDelay = d
+		c.lowlevel = true
+	}
+}
+
+// WithPostStartDelay makes the transactors wait for d after each start
+// and repeated start condition, c.f. Transact8x8Options.PostStartDelay.
+func WithPostStartDelay(d time.Duration) TransactorOption {
+	return func(c *transactorConfig) {
+		c.opts.PostStartDelay = d
+		c.lowlevel = true
+	}
+}
+
+// WithByteAtATime makes the transactors transfer each data byte in a
+// transaction of its own, for broken devices only, c.f.
+// Transact8x8Options.ByteAtATime.
+func WithByteAtATime() TransactorOption {
+	return func(c *transactorConfig) {
+		c.opts.ByteAtATime = true
+		c.lowlevel = true
+	}
+}
+
+// WithStopOnNACK makes the transactors end the write phase when the
+// device NACKs a data byte, without an error unless a read was
+// requested, c.f. Transact8x8Options.StopOnNACK.
+func WithStopOnNACK() TransactorOption {
+	return func(c *transactorConfig) {
+		c.opts.StopOnNACK = true
+		c.lowlevel = true
+	}
+}
+
+// WithNACKPolicy makes the transactors map NACKs of address bytes to
+// errors as selected by p, c.f. Transact8x8Options.NACK.
+func WithNACKPolicy(p NACKPolicy) TransactorOption {
+	return func(c *transactorConfig) {
+		c.opts.NACK = p
+		c.lowlevel = true
+	}
+}
+
+// delayMaster waits for d after every byte transferred on m.
+type delayMaster struct {
+	I2CMaster
+	d time.Duration
+}
+
+func (m delayMaster) ReadByte(ack bool) (byte, error) {
+	b, err := m.I2CMaster.ReadByte(ack)
+	sleep(m.d)
+	return b, err
+}
+
+func (m delayMaster) WriteByte(b byte) error {
+	err := m.I2CMaster.WriteByte(b)
+	sleep(m.d)
+	return err
+}
+
+// startDelayMaster waits for d after every start condition on m.
+type startDelayMaster struct {
+	I2CMaster
+	d time.Duration
+}
+
+func (m startDelayMaster) Start() error {
+	err := m.I2CMaster.Start()
+	sleep(m.d)
+	return err
+}
+
+type healthTransactor struct {
+	t   Transactor
+	max int
+
+	mu    sync.Mutex // guards nacks, as the transactor may be shared
+	nacks int        // consecutive NACKed transactions
+}
+
+// check updates the count of consecutive NACKs with the outcome err of
+// a transaction and returns the error to report.
+func (h *healthTransactor) check(err error) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	switch {
+	case err == nil:
+		h.nacks = 0
+	case errors.Is(err, NoSuchDevice) || errors.Is(err, NACKReceived):
+		h.nacks++
+		if h.nacks > h.max {
+			return fmt.Errorf("%w: %w", ErrBusUnhealthy, err)
+		}
+	}
+	return err
+}
+
+func (h *healthTransactor) Transact8x8(addr Addr, regaddr uint8, w []byte, rb []byte) (int, int, error) {
+	nw, nr, err := h.t.Transact8x8(addr, regaddr, w, rb)
+	return nw, nr, h.check(err)
+}
+
+func (h *healthTransactor) Transact16x8(addr Addr, regaddr uint16, w []byte, rb []byte) (int, int, error) {
+	nw, nr, err := h.t.Transact16x8(addr, regaddr, w, rb)
+	return nw, nr, h.check(err)
+}
+
+type retryTransactor struct {
+	t       Transactor
+	retries int
+}
+
+func (r *retryTransactor) Transact8x8(addr Addr, regaddr uint8, w []byte, rb []byte) (nw, nr int, err error) {
+	for i := 0; ; i++ {
+		nw, nr, err = r.t.Transact8x8(addr, regaddr, w, rb)
+		if err == nil || i >= r.retries {
+			return
+		}
+	}
+}
+
+func (r *retryTransactor) Transact16x8(addr Addr, regaddr uint16, w []byte, rb []byte) (nw, nr int, err error) {
+	for i := 0; ; i++ {
+		nw, nr, err = r.t.Transact16x8(addr, regaddr, w, rb)
+		if err == nil || i >= r.retries {
+			return
+		}
+	}
+}
+
+type splitReadTransactor struct {
+	t Transactor
+	n int
+}
+
+func (s *splitReadTransactor) Transact8x8(addr Addr, regaddr uint8, w []byte, rb []byte) (nw, nr int, err error) {
+	if len(rb) <= s.n {
+		return s.t.Transact8x8(addr, regaddr, w, rb)
+	}
+
+	reg := regaddr + uint8(len(w))
+	for nr < len(rb) {
+		chunk := rb[nr:]
+		if len(chunk) > s.n {
+			chunk = chunk[:s.n]
+		}
+
+		// the first transaction writes w at regaddr
+		cr, cw := reg+uint8(nr), []byte(nil)
+		if nr == 0 {
+			cr, cw = regaddr, w
+		}
+		n, m, err := s.t.Transact8x8(addr, cr, cw, chunk)
+		nw += n
+		nr += m
+		if err != nil {
+			return nw, nr, err
+		}
+	}
+	return nw, nr, nil
+}
+
+func (s *splitReadTransactor) Transact16x8(addr Addr, regaddr uint16, w []byte, rb []byte) (nw, nr int, err error) {
+	if len(rb) <= s.n {
+		return s.t.Transact16x8(addr, regaddr, w, rb)
+	}
+
+	reg := regaddr + uint16(len(w))
+	for nr < len(rb) {
+		chunk := rb[nr:]
+		if len(chunk) > s.n {
+			chunk = chunk[:s.n]
+		}
+
+		// the first transaction writes w at regaddr
+		cr, cw := reg+uint16(nr), []byte(nil)
+		if nr == 0 {
+			cr, cw = regaddr, w
+		}
+		n, m, err := s.t.Transact16x8(addr, cr, cw, chunk)
+		nw += n
+		nr += m
+		if err != nil {
+			return nw, nr, err
+		}
+	}
+	return nw, nr, nil
+}
@@ -0,0 +1,308 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSleep replaces sleep for the duration of a test and records the
+// requested delays.
+func fakeSleep(t *testing.T) *[]time.Duration {
+	var delays []time.Duration
+	orig := sleep
+	sleep = func(d time.Duration) { delays = append(delays, d) }
+	t.Cleanup(func() { sleep = orig })
+	return &delays
+}
+
+func TestTransactorOptionsDefault(t *testing.T) {
+	// without options, native transactors are used
+	pvt := newPVT24(Conf_24C02, t)
+	tr := NewTransactor(pvt)
+	tr.Transact8x8(Addr7(0x50), 0, []byte{1}, nil)
+	if len(pvt.log) != 1 {
+		t.Fatalf("expected the native transactor to be used")
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	f := &failingPVT24{PVT24: newPVT24(Conf_24C02, t), failAt: 1}
+	tr := NewTransactor(f, WithRetry(2))
+	if _, _, err := tr.Transact8x8(Addr7(0x50), 0, []byte{1}, nil); err != nil {
+		t.Fatalf("expected the retry to succeed, got %v", err)
+	}
+	if f.n != 2 {
+		t.Fatalf("expected 2 attempts, got %d", f.n)
+	}
+
+	tr = NewTransactor(&alwaysNACK{}, WithRetry(2))
+	if _, _, err := tr.Transact8x8(Addr7(0x50), 0, nil, nil); err != NoSuchDevice {
+		t.Fatalf("expected NoSuchDevice after exhausting retries, got %v", err)
+	}
+}
+
+func TestWithMaxReadLen(t *testing.T) {
+	pvt := newPVT24(Conf_24C128, t)
+	tr := NewTransactor(pvt, WithMaxReadLen(4))
+
+	r := make([]byte, 12)
+	if _, nr, err := tr.Transact8x8(Addr7(0x50), 0x10, nil, r); nr != 12 || err != nil {
+		t.Fatalf("expected to read 12 bytes, got %d, %v", nr, err)
+	}
+	if string(r) != string(pvt.mem[0x10:0x1c]) {
+		t.Fatalf("read % x, expected % x", r, pvt.mem[0x10:0x1c])
+	}
+
+	r = make([]byte, 10)
+	if _, nr, err := tr.Transact16x8(Addr7(0x50), 0x1234, nil, r); nr != 10 || err != nil {
+		t.Fatalf("expected to read 10 bytes, got %d, %v", nr, err)
+	}
+	if string(r) != string(pvt.mem[0x1234:0x123e]) {
+		t.Fatalf("read % x, expected % x", r, pvt.mem[0x1234:0x123e])
+	}
+
+	exp := []struct {
+		regaddr uint16
+		nr      int
+	}{{0x10, 4}, {0x14, 4}, {0x18, 4}, {0x1234, 4}, {0x1238, 4}, {0x123c, 2}}
+	if len(pvt.log) != len(exp) {
+		t.Fatalf("expected %d transactions, got %d", len(exp), len(pvt.log))
+	}
+	for i, e := range exp {
+		if pvt.log[i].regaddr != e.regaddr || pvt.log[i].nr != e.nr {
+			t.Errorf("transaction %d: expected %d bytes at %#x, got %d at %#x", i, e.nr, e.regaddr, pvt.log[i].nr, pvt.log[i].regaddr)
+		}
+	}
+}
+
+func TestWithMaxConsecutiveNACKs(t *testing.T) {
+	dev := &nackAfter{}
+	tr := NewTransactor(dev, WithMaxConsecutiveNACKs(2))
+
+	for i := 0; i < 2; i++ {
+		if _, _, err := tr.Transact8x8(Addr7(0x50), 0, nil, nil); err != NoSuchDevice {
+			t.Fatalf("NACK %d: expected NoSuchDevice, got %v", i, err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		_, _, err := tr.Transact8x8(Addr7(0x50), 0, nil, nil)
+		if !errors.Is(err, ErrBusUnhealthy) || !errors.Is(err, NoSuchDevice) {
+			t.Fatalf("NACK %d: expected ErrBusUnhealthy wrapping NoSuchDevice, got %v", i+2, err)
+		}
+	}
+
+	// a success resets the count
+	dev.n = 2
+	if _, _, err := tr.Transact8x8(Addr7(0x50), 0, nil, nil); err != nil {
+		t.Fatalf("expected the transaction to succeed, got %v", err)
+	}
+	if _, _, err := tr.Transact8x8(Addr7(0x50), 0, nil, nil); err != NoSuchDevice {
+		t.Fatalf("expected NoSuchDevice after the reset, got %v", err)
+	}
+
+	// retries count as well
+	dev.n = 0
+	tr = NewTransactor(dev, WithMaxConsecutiveNACKs(2), WithRetry(5))
+	if _, _, err := tr.Transact8x8(Addr7(0x50), 0, nil, nil); !errors.Is(err, ErrBusUnhealthy) {
+		t.Fatalf("expected retries to exceed the limit, got %v", err)
+	}
+}
+
+func TestWithMaxConsecutiveNACKsConcurrent(t *testing.T) {
+	tr := NewTransactor(&alwaysNACK{}, WithMaxConsecutiveNACKs(20))
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 5; i++ {
+				tr.Transact8x8(Addr7(0x50), 0, nil, nil)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// all 20 NACKs were counted, so the next one exceeds the limit
+	if _, _, err := tr.Transact8x8(Addr7(0x50), 0, nil, nil); !errors.Is(err, ErrBusUnhealthy) {
+		t.Fatalf("expected ErrBusUnhealthy after 20 NACKs, got %v", err)
+	}
+}
+
+func TestWithInterByteDelay(t *testing.T) {
+	delays := fakeSleep(t)
+
+	tr := NewTransactor(newmemdev256(Addr7(0x50)), WithInterByteDelay(time.Millisecond))
+	if _, _, err := tr.Transact8x8(Addr7(0x50), 0, []byte{1, 2}, nil); err != nil {
+		t.Fatalf("transaction failed: %v", err)
+	}
+	// address, register address and two data bytes
+	if len(*delays) != 4 {
+		t.Fatalf("expected 4 delays, got %v", *delays)
+	}
+}
+
+func TestWithNoRepeatedStart(t *testing.T) {
+	md256 := newmemdev256(Addr7(0x50))
+	m := NewRecordingMaster(md256)
+	tr := NewTransactor(m, WithNoRepeatedStart())
+	if _, _, err := tr.Transact8x8(Addr7(0x50), 0x30, nil, make([]byte, 1)); err != nil {
+		t.Fatalf("transaction failed: %v", err)
+	}
+	checkLog(t, recorded(m), []i2cItem{{t_START, 0, false, nil},
+		{t_WRITE, 0xa0, false, nil},
+		{t_WRITE, 0x30, false, nil},
+		{t_STOP, 0x00, false, nil},
+		{t_START, 0x00, false, nil},
+		{t_WRITE, 0xa1, false, nil},
+		{t_READ, 0x00, false, nil},
+		{t_STOP, 0x00, false, nil},
+	})
+}
+
+func TestWithReadPhaseDelay(t *testing.T) {
+	delays := fakeSleep(t)
+
+	tr := NewTransactor(newmemdev256(Addr7(0x50)), WithReadPhaseDelay(10*time.Millisecond))
+	if _, _, err := tr.Transact8x8(Addr7(0x50), 0, []byte{1}, nil); err != nil {
+		t.Fatalf("write transaction failed: %v", err)
+	}
+	if len(*delays) != 0 {
+		t.Fatalf("expected no delay for a write-only transaction, got %v", *delays)
+	}
+
+	if _, _, err := tr.Transact8x8(Addr7(0x50), 0, []byte{1}, make([]byte, 3)); err != nil {
+		t.Fatalf("read transaction failed: %v", err)
+	}
+	if len(*delays) != 1 || (*delays)[0] != 10*time.Millisecond {
+		t.Fatalf("expected a single delay of 10ms, got %v", *delays)
+	}
+}
+
+func TestWithPostStartDelay(t *testing.T) {
+	delays := fakeSleep(t)
+
+	tr := NewTransactor(newmemdev256(Addr7(0x50)), WithPostStartDelay(time.Millisecond), WithReadPhaseDelay(10*time.Millisecond))
+	if _, _, err := tr.Transact8x8(Addr7(0x50), 0, []byte{1}, nil); err != nil {
+		t.Fatalf("write transaction failed: %v", err)
+	}
+	if fmt.Sprint(*delays) != "[1ms]" {
+		t.Fatalf("expected a single delay after the start, got %v", *delays)
+	}
+
+	// the read phase delay precedes the repeated start, the post start
+	// delay follows it
+	*delays = nil
+	if _, _, err := tr.Transact8x8(Addr7(0x50), 0, []byte{1}, make([]byte, 3)); err != nil {
+		t.Fatalf("read transaction failed: %v", err)
+	}
+	if fmt.Sprint(*delays) != "[1ms 10ms 1ms]" {
+		t.Fatalf("expected delays after the start and the repeated start, got %v", *delays)
+	}
+}
+
+func TestWithByteAtATime(t *testing.T) {
+	md := newmemdev256(Addr7(0x50))
+	m := NewRecordingMaster(md)
+	tr := NewTransactor(m, WithByteAtATime())
+
+	if nw, _, err := tr.Transact8x8(Addr7(0x50), 0x20, []byte{1, 2, 3}, nil); nw != 3 || err != nil {
+		t.Fatalf("expected to write 3 bytes, got %d, %v", nw, err)
+	}
+	if string(md.mem[0x20:0x23]) != "\x01\x02\x03" {
+		t.Fatalf("unexpected memory contents % x", md.mem[0x20:0x23])
+	}
+
+	// one transaction per byte
+	starts := 0
+	for _, l := range recorded(m) {
+		if l.typ == t_START {
+			starts++
+		}
+	}
+	if starts != 3 {
+		t.Fatalf("expected 3 transactions, got %d", starts)
+	}
+
+	r := make([]byte, 3)
+	if _, nr, err := tr.Transact8x8(Addr7(0x50), 0x20, nil, r); nr != 3 || err != nil || string(r) != "\x01\x02\x03" {
+		t.Fatalf("expected to read back 01 02 03, got %d, %v, % x", nr, err, r)
+	}
+}
+
+// nackAfter ACKs the first n bytes written and NACKs all further ones.
+type nackAfter struct {
+	scriptedMaster
+	n int
+}
+
+func (m *nackAfter) WriteByte(b byte) error {
+	if m.n == 0 {
+		return NACKReceived
+	}
+	m.n--
+	return nil
+}
+
+func TestWithStopOnNACK(t *testing.T) {
+	// address, register address and two data bytes are ACKed
+	m := NewRecordingMaster(&nackAfter{n: 4})
+	tr := NewTransactor(m, WithStopOnNACK())
+	nw, nr, err := tr.Transact8x8(Addr7(0x50), 0x10, []byte{1, 2, 3, 4}, nil)
+	if nw != 2 || nr != 0 || err != nil {
+		t.Fatalf("expected (2, 0, nil), got (%d, %d, %v)", nw, nr, err)
+	}
+	checkLog(t, recorded(m), []i2cItem{{t_START, 0, false, nil},
+		{t_WRITE, 0xa0, false, nil},
+		{t_WRITE, 0x10, false, nil},
+		{t_WRITE, 0x01, false, nil},
+		{t_WRITE, 0x02, false, nil},
+		{t_WRITE, 0x03, false, NACKReceived},
+		{t_STOP, 0x00, false, nil},
+	})
+
+	// a requested read which was skipped is an error
+	tr = NewTransactor(&nackAfter{n: 4}, WithStopOnNACK())
+	nw, nr, err = tr.Transact8x8(Addr7(0x50), 0x10, []byte{1, 2, 3, 4}, make([]byte, 1))
+	if nw != 2 || nr != 0 || err != ErrReadPhaseSkipped {
+		t.Fatalf("expected (2, 0, ErrReadPhaseSkipped), got (%d, %d, %v)", nw, nr, err)
+	}
+
+	// without the option, the NACK is an error
+	tr = NewTransactor(&nackAfter{n: 4})
+	if nw, _, err := tr.Transact8x8(Addr7(0x50), 0x10, []byte{1, 2, 3, 4}, nil); nw != 2 || err != NACKReceived {
+		t.Fatalf("expected 2 bytes written and NACKReceived, got %d, %v", nw, err)
+	}
+}
+
+func TestWithNACKPolicy(t *testing.T) {
+	errBusy := errors.New("device busy")
+
+	cases := []struct {
+		policy NACKPolicy
+		acked  int // number of bytes ACKed before the NACK
+		exp    error
+	}{
+		{NACKPolicy{}, 0, NoSuchDevice},
+		{NACKPolicy{}, 2, NoSuchDevice},
+		{NACKPolicy{Address: NACKReceived}, 0, NACKReceived},
+		{NACKPolicy{Address: NACKReceived}, 2, NoSuchDevice},
+		{NACKPolicy{ReadAddress: errBusy}, 0, NoSuchDevice},
+		{NACKPolicy{ReadAddress: errBusy}, 2, errBusy},
+	}
+
+	for i, c := range cases {
+		// the address and the register address precede the read address
+		tr := NewTransactor(&nackAfter{n: c.acked}, WithNACKPolicy(c.policy))
+		if _, _, err := tr.Transact8x8(Addr7(0x50), 0x10, nil, make([]byte, 1)); err != c.exp {
+			t.Errorf("case %d: expected %v, got %v", i, c.exp, err)
+		}
+	}
+}
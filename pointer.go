@@ -0,0 +1,58 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"errors"
+)
+
+// PointerTransactor accesses devices whose register pointer is written
+// with a different width for writes than for reads, e.g. a 16 bit
+// pointer preceding written data but an 8 bit pointer preceding reads.
+// Pointers wider than 8 bits are sent most significant byte first.
+//
+// A write is carried out as follows, with wptr being the pointer bytes:
+// 		[S] [(devaddr<<1)] A [wptr...] A [w[0]] A ... [P]
+//
+// A read is carried out as follows, with rptr being the pointer bytes:
+// 		[S] [(devaddr<<1)] A [rptr...] A [S] [(devaddr<<1)|1] r[0] [A] ... r[len(r)-1] [N] [P]
+type PointerTransactor struct {
+	m    I2CMaster
+	wlen int
+	rlen int
+}
+
+// NewPointerTransactor returns a PointerTransactor which sends pointers
+// of writePtrBytes bytes before writes and pointers of readPtrBytes bytes
+// before reads. Both widths need to be either 1 or 2 bytes.
+func NewPointerTransactor(m I2CMaster, writePtrBytes, readPtrBytes int) (*PointerTransactor, error) {
+	if writePtrBytes < 1 || writePtrBytes > 2 || readPtrBytes < 1 || readPtrBytes > 2 {
+		return nil, errors.New("NewPointerTransactor: pointer widths need to be 1 or 2 bytes")
+	}
+
+	return &PointerTransactor{m, writePtrBytes, readPtrBytes}, nil
+}
+
+func ptrBytes(buf *[2]byte, ptr uint16, n int) []byte {
+	buf[0] = uint8(ptr >> 8)
+	buf[1] = uint8(ptr)
+	return buf[2-n:]
+}
+
+// Write writes w to the device at addr, starting at register ptr. It
+// returns the number of bytes of w written.
+func (p *PointerTransactor) Write(addr Addr, ptr uint16, w []byte) (int, error) {
+	var buf [2]byte
+	res := transact(p.m, &Transact8x8Options{}, addr, ptrBytes(&buf, ptr, p.wlen), w, nil)
+	return res.BytesWritten, res.Err
+}
+
+// Read reads len(r) bytes from the device at addr, starting at register
+// ptr. It returns the number of bytes read.
+func (p *PointerTransactor) Read(addr Addr, ptr uint16, r []byte) (int, error) {
+	var buf [2]byte
+	res := transact(p.m, &Transact8x8Options{}, addr, ptrBytes(&buf, ptr, p.rlen), nil, r)
+	return res.BytesRead, res.Err
+}
@@ -0,0 +1,63 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"testing"
+)
+
+// scriptedMaster ACKs all writes and returns the bytes in rd on reads.
+type scriptedMaster struct {
+	rd []byte
+}
+
+func (s *scriptedMaster) Start() error { return nil }
+func (s *scriptedMaster) Stop() error  { return nil }
+
+func (s *scriptedMaster) WriteByte(b byte) error { return nil }
+
+func (s *scriptedMaster) ReadByte(ack bool) (byte, error) {
+	b := s.rd[0]
+	s.rd = s.rd[1:]
+	return b, nil
+}
+
+func TestPointerTransactor(t *testing.T) {
+	if _, err := NewPointerTransactor(nil, 3, 1); err == nil {
+		t.Fatalf("NewPointerTransactor accepted a 3 byte pointer")
+	}
+
+	m := NewRecordingMaster(&scriptedMaster{rd: []byte{0x11, 0x22}})
+	p, err := NewPointerTransactor(m, 2, 1)
+	if err != nil {
+		t.Fatalf("NewPointerTransactor failed: %v", err)
+	}
+
+	if n, err := p.Write(Addr7(0x50), 0x1234, []byte{0xab}); n != 1 || err != nil {
+		t.Fatalf("expected to write 1 byte, got %d, %v", n, err)
+	}
+	checkLog(t, recorded(m), []i2cItem{{t_START, 0, false, nil},
+		{t_WRITE, 0xa0, false, nil},
+		{t_WRITE, 0x12, false, nil}, // pointer hi
+		{t_WRITE, 0x34, false, nil}, // pointer lo
+		{t_WRITE, 0xab, false, nil},
+		{t_STOP, 0x00, false, nil},
+	})
+
+	m.Reset()
+	rb := make([]byte, 2)
+	if n, err := p.Read(Addr7(0x50), 0x1234, rb); n != 2 || err != nil {
+		t.Fatalf("expected to read 2 bytes, got %d, %v", n, err)
+	}
+	checkLog(t, recorded(m), []i2cItem{{t_START, 0, false, nil},
+		{t_WRITE, 0xa0, false, nil},
+		{t_WRITE, 0x34, false, nil}, // 8 bit pointer
+		{t_START, 0x00, false, nil},
+		{t_WRITE, 0xa1, false, nil},
+		{t_READ, 0x11, true, nil},
+		{t_READ, 0x22, false, nil},
+		{t_STOP, 0x00, false, nil},
+	})
+}
@@ -0,0 +1,30 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+// PreparedTransaction repeatedly reads a fixed number of bytes from a
+// register, as in polling loops. Its read buffer is allocated once, by
+// Prepare8x8, and reused by every Execute.
+type PreparedTransaction struct {
+	tr      Transactor8x8
+	addr    Addr
+	regaddr uint8
+	buf     []byte
+}
+
+// Prepare8x8 returns a PreparedTransaction which reads readLen bytes
+// from register regaddr of the device at addr using tr.
+func Prepare8x8(tr Transactor8x8, addr Addr, regaddr uint8, readLen int) *PreparedTransaction {
+	return &PreparedTransaction{tr, addr, regaddr, make([]byte, readLen)}
+}
+
+// Execute carries out the transaction. The returned slice is the
+// PreparedTransaction's internal buffer, so it is only valid until the
+// next call to Execute and must be copied to be kept. On errors, the
+// bytes read before the error are returned.
+func (p *PreparedTransaction) Execute() ([]byte, error) {
+	_, nr, err := p.tr.Transact8x8(p.addr, p.regaddr, nil, p.buf)
+	return p.buf[:nr], err
+}
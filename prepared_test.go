@@ -0,0 +1,49 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"testing"
+)
+
+func TestPreparedTransaction(t *testing.T) {
+	md256 := newmemdev256(Addr7(0x48))
+	copy(md256.mem[0x10:], []byte{0x12, 0x34})
+	p := Prepare8x8(NewTransact8x8(md256), Addr7(0x48), 0x10, 2)
+
+	b, err := p.Execute()
+	if err != nil || string(b) != "\x12\x34" {
+		t.Fatalf("expected to read 12 34, got % x, %v", b, err)
+	}
+
+	md256.mem[0x11] = 0x56
+	b2, err := p.Execute()
+	if err != nil || string(b2) != "\x12\x56" {
+		t.Fatalf("expected to read 12 56, got % x, %v", b2, err)
+	}
+	if &b[0] != &b2[0] {
+		t.Fatalf("Execute did not reuse its buffer")
+	}
+
+	if allocs := testing.AllocsPerRun(100, func() { p.Execute() }); allocs != 0 {
+		t.Errorf("expected no allocations per Execute, got %v", allocs)
+	}
+}
+
+func BenchmarkPreparedTransaction(b *testing.B) {
+	p := Prepare8x8(NewTransact8x8(newmemdev256(Addr7(0x48))), Addr7(0x48), 0x10, 2)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p.Execute()
+	}
+}
+
+func BenchmarkTransact8x8Read(b *testing.B) {
+	tr := NewTransact8x8(newmemdev256(Addr7(0x48)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		tr.Transact8x8(Addr7(0x48), 0x10, nil, make([]byte, 2))
+	}
+}
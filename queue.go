@@ -0,0 +1,82 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"errors"
+	"sync"
+)
+
+// QueueStopped is returned by TxQueue.Do after the queue's worker
+// has been stopped.
+var QueueStopped = errors.New("transaction queue stopped")
+
+// TxQueue serializes units of work against a single I2C master. Units
+// of work are executed one at a time by a single worker goroutine, in
+// the order in which they were submitted.
+type TxQueue struct {
+	tr   Transactor
+	jobs chan txJob
+	quit chan struct{}
+	done chan struct{}
+}
+
+type txJob struct {
+	fn  func(Transactor) error
+	res chan error
+}
+
+// NewTransactionQueue starts a worker goroutine which executes the work
+// submitted to the returned TxQueue on m. The returned function stops
+// the worker. It waits for the unit of work in progress, if any, to
+// finish. Calling it more than once is harmless.
+func NewTransactionQueue(m I2CMaster) (*TxQueue, func()) {
+	q := &TxQueue{
+		tr:   NewTransactor(m),
+		jobs: make(chan txJob),
+		quit: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	go q.work()
+
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			close(q.quit)
+			<-q.done
+		})
+	}
+
+	return q, stop
+}
+
+func (q *TxQueue) work() {
+	defer close(q.done)
+	for {
+		select {
+		case j := <-q.jobs:
+			j.res <- j.fn(q.tr)
+		case <-q.quit:
+			return
+		}
+	}
+}
+
+// Do submits fn to the queue and waits for it to be executed. No other
+// unit of work submitted to the queue is executed while fn runs, so fn
+// may issue several transactions which must not be interleaved with
+// other goroutines' transactions. Do returns fn's error or QueueStopped
+// if the queue was stopped before fn could be executed.
+func (q *TxQueue) Do(fn func(Transactor) error) error {
+	j := txJob{fn, make(chan error, 1)}
+	select {
+	case q.jobs <- j:
+	case <-q.quit:
+		return QueueStopped
+	}
+
+	return <-j.res
+}
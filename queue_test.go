@@ -0,0 +1,49 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestTxQueue(t *testing.T) {
+	md256 := newmemdev256(Addr7(0x50))
+	q, stop := NewTransactionQueue(md256)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := q.Do(func(tr Transactor) error {
+				// write and read back in two transactions, which must
+				// not be interleaved with other goroutines' work
+				if _, _, err := tr.Transact8x8(Addr7(0x50), 0, []byte{uint8(i)}, nil); err != nil {
+					return err
+				}
+				rb := make([]byte, 1)
+				if _, _, err := tr.Transact8x8(Addr7(0x50), 0, nil, rb); err != nil {
+					return err
+				}
+				if rb[0] != uint8(i) {
+					t.Errorf("goroutine %d: read back %d, work was interleaved", i, rb[0])
+				}
+				return nil
+			})
+			if err != nil {
+				t.Errorf("goroutine %d: Do failed: %v", i, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	stop()
+	stop()
+
+	if err := q.Do(func(Transactor) error { return nil }); err != QueueStopped {
+		t.Fatalf("expected QueueStopped after stop, got %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"sync"
+	"time"
+)
+
+type rateLimiter struct {
+	t   Transactor
+	min time.Duration
+
+	mu   sync.Mutex // held for the duration of a transaction
+	last time.Time  // start of the last transaction
+}
+
+// RateLimit returns a Transactor which carries out transactions on t
+// such that at least minInterval passes between the starts of two
+// consecutive transactions, waiting as needed. It may be used by
+// several goroutines, whose transactions are carried out one at a time.
+func RateLimit(t Transactor, minInterval time.Duration) Transactor {
+	return &rateLimiter{t: t, min: minInterval}
+}
+
+// begin waits until the next transaction may start and locks r. The
+// caller needs to unlock r after the transaction.
+func (r *rateLimiter) begin() {
+	r.mu.Lock()
+	if !r.last.IsZero() {
+		if d := r.min - time.Since(r.last); d > 0 {
+			sleep(d)
+		}
+	}
+	r.last = time.Now()
+}
+
+func (r *rateLimiter) Transact8x8(addr Addr, regaddr uint8, w []byte, rb []byte) (int, int, error) {
+	r.begin()
+	defer r.mu.Unlock()
+	return r.t.Transact8x8(addr, regaddr, w, rb)
+}
+
+func (r *rateLimiter) Transact16x8(addr Addr, regaddr uint16, w []byte, rb []byte) (int, int, error) {
+	r.begin()
+	defer r.mu.Unlock()
+	return r.t.Transact16x8(addr, regaddr, w, rb)
+}
@@ -0,0 +1,57 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// startRecorder records the start times of its transactions.
+type startRecorder struct {
+	starts []time.Time
+}
+
+func (s *startRecorder) Transact8x8(addr Addr, regaddr uint8, w, r []byte) (int, int, error) {
+	s.starts = append(s.starts, time.Now())
+	return len(w), len(r), nil
+}
+
+func (s *startRecorder) Transact16x8(addr Addr, regaddr uint16, w, r []byte) (int, int, error) {
+	s.starts = append(s.starts, time.Now())
+	return len(w), len(r), nil
+}
+
+func TestRateLimit(t *testing.T) {
+	const interval = 2 * time.Millisecond
+	s := &startRecorder{}
+	tr := RateLimit(s, interval)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 3; j++ {
+				tr.Transact8x8(Addr7(0x50), 0, nil, nil)
+				tr.Transact16x8(Addr7(0x50), 0, nil, nil)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(s.starts) != 24 {
+		t.Fatalf("expected 24 transactions, got %d", len(s.starts))
+	}
+	// the recorded starts lag the limiter's notion of the start by the
+	// time it takes to call the transactor
+	const slack = interval / 10
+	for i := 1; i < len(s.starts); i++ {
+		if d := s.starts[i].Sub(s.starts[i-1]); d < interval-slack {
+			t.Fatalf("transactions %d and %d started %v apart, expected at least %v", i-1, i, d, interval)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// fixtureHeader is the first line of fixtures written by WriteFixture.
+const fixtureHeader = "# i2cm fixture v1"
+
+type recordedOp struct {
+	op  byte  // 'S' start, 'P' stop, 'W' write, 'R' read
+	b   byte  // byte written or read
+	ack bool  // for reads, whether the byte was ACKed
+	err error // outcome
+}
+
+func (o recordedOp) String() string {
+	switch o.op {
+	case 'W':
+		return fmt.Sprintf("W %02x", o.b)
+	case 'R':
+		if o.ack {
+			return fmt.Sprintf("R %02x A", o.b)
+		}
+		return fmt.Sprintf("R %02x N", o.b)
+	}
+	return string(o.op)
+}
+
+// Recorder is a RecordingMaster whose trace can be written to a
+// fixture and replayed by LoadReplayMaster.
+type Recorder struct {
+	*RecordingMaster
+}
+
+// NewRecorder returns a Recorder carrying out operations on m.
+func NewRecorder(m I2CMaster) *Recorder {
+	return &Recorder{NewRecordingMaster(m)}
+}
+
+// traceOpCodes are the fixture codes of the TraceOps.
+var traceOpCodes = [...]byte{TraceStart: 'S', TraceStop: 'P', TraceRead: 'R', TraceWrite: 'W'}
+
+// WriteFixture writes the operations recorded since the last Reset to
+// w, one per line: the operation, the byte written or read, for reads
+// whether the byte was ACKed, and the outcome, e.g.
+//
+//	S ok
+//	W a0 nack
+//	R 12 N ok
+//	W 34 err bus timeout
+//
+// Errors other than NACKReceived are stored by their message.
+func (r *Recorder) WriteFixture(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, fixtureHeader)
+	for _, e := range r.Log() {
+		o := recordedOp{traceOpCodes[e.Op], e.Byte, e.ACK, e.Err}
+		switch {
+		case o.err == nil:
+			fmt.Fprintf(bw, "%v ok\n", o)
+		case o.err == NACKReceived:
+			fmt.Fprintf(bw, "%v nack\n", o)
+		default:
+			msg := strings.ReplaceAll(o.err.Error(), "\n", " ")
+			fmt.Fprintf(bw, "%v err %s\n", o, msg)
+		}
+	}
+	return bw.Flush()
+}
+
+// parseFixtureLine parses a line written by WriteFixture.
+func parseFixtureLine(line string) (recordedOp, error) {
+	var o recordedOp
+	f := strings.SplitN(line, " ", 2)
+	if len(f[0]) != 1 || len(f) < 2 {
+		return o, errors.New("malformed operation")
+	}
+	o.op, line = f[0][0], f[1]
+
+	switch o.op {
+	case 'S', 'P':
+	case 'W', 'R':
+		f = strings.SplitN(line, " ", 2)
+		b, err := strconv.ParseUint(f[0], 16, 8)
+		if err != nil || len(f) < 2 {
+			return o, errors.New("malformed byte")
+		}
+		o.b, line = byte(b), f[1]
+		if o.op == 'R' {
+			f = strings.SplitN(line, " ", 2)
+			if (f[0] != "A" && f[0] != "N") || len(f) < 2 {
+				return o, errors.New("malformed ACK")
+			}
+			o.ack, line = f[0] == "A", f[1]
+		}
+	default:
+		return o, fmt.Errorf("unknown operation %q", o.op)
+	}
+
+	switch {
+	case line == "ok":
+	case line == "nack":
+		o.err = NACKReceived
+	case strings.HasPrefix(line, "err "):
+		o.err = errors.New(line[len("err "):])
+	default:
+		return o, errors.New("malformed outcome")
+	}
+	return o, nil
+}
+
+type replayMaster struct {
+	ops []recordedOp
+	err error // divergence, reported by all further operations
+}
+
+// LoadReplayMaster reads a fixture written by Recorder.WriteFixture and
+// returns an I2CMaster replaying it: the operations need to be carried
+// out in the recorded order with the recorded bytes written and ACKs
+// given, and return the recorded outcomes and bytes read. On a
+// divergence from the recording, or operations past its end, the
+// operation and all following ones fail with an error describing the
+// divergence.
+func LoadReplayMaster(r io.Reader) (I2CMaster, error) {
+	s := bufio.NewScanner(r)
+	if !s.Scan() || s.Text() != fixtureHeader {
+		if err := s.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("LoadReplayMaster: not an i2cm fixture")
+	}
+
+	var m replayMaster
+	for line := 2; s.Scan(); line++ {
+		if s.Text() == "" {
+			continue
+		}
+		o, err := parseFixtureLine(s.Text())
+		if err != nil {
+			return nil, fmt.Errorf("LoadReplayMaster: line %d: %v", line, err)
+		}
+		m.ops = append(m.ops, o)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// next consumes the next recorded operation, which needs to match got.
+func (m *replayMaster) next(got recordedOp) (recordedOp, error) {
+	if m.err != nil {
+		return recordedOp{}, m.err
+	}
+	if len(m.ops) == 0 {
+		m.err = fmt.Errorf("replay: %v past the end of the recording", got)
+		return recordedOp{}, m.err
+	}
+
+	o := m.ops[0]
+	match := o.op == got.op
+	switch o.op {
+	case 'W':
+		match = match && o.b == got.b
+	case 'R':
+		match = match && o.ack == got.ack
+	}
+	if !match {
+		m.err = fmt.Errorf("replay: expected %v, got %v", o, got)
+		return recordedOp{}, m.err
+	}
+
+	m.ops = m.ops[1:]
+	return o, nil
+}
+
+func (m *replayMaster) Start() error {
+	o, err := m.next(recordedOp{op: 'S'})
+	if err != nil {
+		return err
+	}
+	return o.err
+}
+
+func (m *replayMaster) Stop() error {
+	o, err := m.next(recordedOp{op: 'P'})
+	if err != nil {
+		return err
+	}
+	return o.err
+}
+
+func (m *replayMaster) WriteByte(b byte) error {
+	o, err := m.next(recordedOp{op: 'W', b: b})
+	if err != nil {
+		return err
+	}
+	return o.err
+}
+
+func (m *replayMaster) ReadByte(ack bool) (byte, error) {
+	o, err := m.next(recordedOp{op: 'R', ack: ack})
+	if err != nil {
+		return 0, err
+	}
+	return o.b, o.err
+}
@@ -0,0 +1,74 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRecorderReplay(t *testing.T) {
+	md := newmemdev256(Addr7(0x50))
+	copy(md.mem[0x10:], []byte{0xaa, 0xbb})
+	bus := NewBusSanityMaster(&memBus{devs: []*memdev256{md}})
+	rec := NewRecorder(NewFaultInjector(bus, []Fault{{Op: FaultWrite, Index: 6, Err: errors.New("bus\ntimeout")}}))
+
+	// a session against the "hardware"
+	session := func(m I2CMaster) ([]byte, error, error) {
+		tr := NewTransact8x8(m)
+		r := make([]byte, 2)
+		if _, _, err := tr.Transact8x8(Addr7(0x50), 0x10, nil, r); err != nil {
+			return nil, err, nil
+		}
+		_, _, nackErr := tr.Transact8x8(Addr7(0x51), 0x00, nil, nil)
+		_, _, faultErr := tr.Transact8x8(Addr7(0x50), 0x00, []byte{1}, nil)
+		if nackErr != NoSuchDevice {
+			return nil, nackErr, nil
+		}
+		return r, nil, faultErr
+	}
+
+	r, err, faultErr := session(rec)
+	if err != nil || string(r) != "\xaa\xbb" || faultErr == nil {
+		t.Fatalf("recording session failed: % x, %v, %v", r, err, faultErr)
+	}
+
+	var fixture bytes.Buffer
+	if err := rec.WriteFixture(&fixture); err != nil {
+		t.Fatalf("WriteFixture failed: %v", err)
+	}
+	if !strings.Contains(fixture.String(), "W a2 nack\n") || !strings.Contains(fixture.String(), "W 01 err bus timeout\n") {
+		t.Fatalf("unexpected fixture:\n%s", fixture.String())
+	}
+
+	// the replay reproduces the session without the device
+	m, err := LoadReplayMaster(bytes.NewReader(fixture.Bytes()))
+	if err != nil {
+		t.Fatalf("LoadReplayMaster failed: %v", err)
+	}
+	r, err, faultErr = session(m)
+	if err != nil || string(r) != "\xaa\xbb" || faultErr == nil || faultErr.Error() != "bus timeout" {
+		t.Fatalf("replayed session differs: % x, %v, %v", r, err, faultErr)
+	}
+	if err := m.Start(); err == nil {
+		t.Fatalf("operation past the end of the recording succeeded")
+	}
+
+	// divergent writes fail
+	m, _ = LoadReplayMaster(bytes.NewReader(fixture.Bytes()))
+	tr := NewTransact8x8(m)
+	if _, _, err := tr.Transact8x8(Addr7(0x50), 0x11, nil, make([]byte, 2)); err == nil || !strings.Contains(err.Error(), "expected W 10, got W 11") {
+		t.Fatalf("expected a divergence error, got %v", err)
+	}
+
+	if _, err := LoadReplayMaster(strings.NewReader("S ok\n")); err == nil {
+		t.Fatalf("fixture without header accepted")
+	}
+	if _, err := LoadReplayMaster(strings.NewReader(fixtureHeader + "\nR zz A ok\n")); err == nil {
+		t.Fatalf("malformed fixture accepted")
+	}
+}
@@ -0,0 +1,305 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RegDev represents a device with 8 bit register addresses and 8 bit
+// registers, as found in many sensors and peripheral chips.
+type RegDev struct {
+	tr   Transactor8x8
+	addr Addr
+}
+
+// NewRegDev returns a RegDev for the device at addr on m's bus.
+func NewRegDev(m I2CMaster, addr Addr) *RegDev {
+	return &RegDev{NewTransact8x8(m), addr}
+}
+
+// ReadRegs reads len(b) consecutive registers starting at reg in a
+// single transaction.
+func (d *RegDev) ReadRegs(reg uint8, b []byte) error {
+	_, _, err := d.tr.Transact8x8(d.addr, reg, nil, b)
+	return err
+}
+
+// WriteRegs writes b to consecutive registers starting at reg in a
+// single transaction.
+func (d *RegDev) WriteRegs(reg uint8, b []byte) error {
+	_, _, err := d.tr.Transact8x8(d.addr, reg, b, nil)
+	return err
+}
+
+// fieldMask returns the mask of a bit field of width bits starting at
+// bit lsb of a register.
+func fieldMask(lsb, width int) (uint8, error) {
+	if lsb < 0 || width < 1 || lsb+width > 8 {
+		return 0, fmt.Errorf("bit field of %d bits at bit %d does not fit into a register", width, lsb)
+	}
+	return uint8((1<<uint(width) - 1) << uint(lsb)), nil
+}
+
+// ReadField reads register reg and returns the bit field of width bits
+// starting at bit lsb, shifted down to bit 0.
+func (d *RegDev) ReadField(reg uint8, lsb, width int) (uint8, error) {
+	mask, err := fieldMask(lsb, width)
+	if err != nil {
+		return 0, fmt.Errorf("RegDev.ReadField: %v", err)
+	}
+
+	var b [1]byte
+	if err := d.ReadRegs(reg, b[:]); err != nil {
+		return 0, err
+	}
+	return (b[0] & mask) >> uint(lsb), nil
+}
+
+// WriteField sets the bit field of width bits starting at bit lsb of
+// register reg to val. The other bits of the register are preserved by
+// reading the register before writing it, so the register is accessed
+// in two transactions.
+func (d *RegDev) WriteField(reg uint8, lsb, width int, val uint8) error {
+	mask, err := fieldMask(lsb, width)
+	if err != nil {
+		return fmt.Errorf("RegDev.WriteField: %v", err)
+	}
+	if val > mask>>uint(lsb) {
+		return fmt.Errorf("RegDev.WriteField: value %#02x does not fit into %d bits", val, width)
+	}
+
+	var b [1]byte
+	if err := d.ReadRegs(reg, b[:]); err != nil {
+		return err
+	}
+	b[0] = b[0]&^mask | val<<uint(lsb)
+	return d.WriteRegs(reg, b[:])
+}
+
+// Scaler converts raw register values to engineering units:
+// value*Scale + Offset.
+type Scaler struct {
+	Offset, Scale float64
+	Signed        bool // whether the raw value is a two's complement number
+}
+
+// ReadScaled reads n consecutive registers starting at reg, which hold
+// a raw value of n bytes in byte order order, and returns the value
+// converted by s. n needs to be 1, 2, 4 or 8; order is not used for
+// single byte values.
+func (d *RegDev) ReadScaled(reg uint8, n int, s Scaler, order binary.ByteOrder) (float64, error) {
+	var b [8]byte
+	switch n {
+	case 1, 2, 4, 8:
+	default:
+		return 0, fmt.Errorf("RegDev.ReadScaled: invalid value size of %d bytes", n)
+	}
+	if err := d.ReadRegs(reg, b[:n]); err != nil {
+		return 0, err
+	}
+
+	var u uint64
+	switch n {
+	case 1:
+		u = uint64(b[0])
+	case 2:
+		u = uint64(order.Uint16(b[:]))
+	case 4:
+		u = uint64(order.Uint32(b[:]))
+	case 8:
+		u = order.Uint64(b[:])
+	}
+
+	var v float64
+	if s.Signed {
+		shift := uint(64 - 8*n)
+		v = float64(int64(u<<shift) >> shift)
+	} else {
+		v = float64(u)
+	}
+	return v*s.Scale + s.Offset, nil
+}
+
+// regField is a struct field tagged for ReadInto.
+type regField struct {
+	index int // field index in the struct
+	reg   uint
+	n     uint // number of registers
+	le    bool // little endian byte order
+}
+
+// parseRegTag parses an i2c struct tag of the form
+// "reg=0x00,len=2,order=be". defn is the length used if len is not
+// given.
+func parseRegTag(tag string, defn uint) (regField, error) {
+	var f regField
+	f.n = defn
+	hasreg := false
+
+	for _, kv := range strings.Split(tag, ",") {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			return f, fmt.Errorf("malformed tag item %q", kv)
+		}
+		k, v := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+
+		switch k {
+		case "reg":
+			reg, err := strconv.ParseUint(v, 0, 8)
+			if err != nil {
+				return f, fmt.Errorf("invalid register %q", v)
+			}
+			f.reg = uint(reg)
+			hasreg = true
+		case "len":
+			n, err := strconv.ParseUint(v, 0, 8)
+			if err != nil || n == 0 {
+				return f, fmt.Errorf("invalid length %q", v)
+			}
+			f.n = uint(n)
+		case "order":
+			switch v {
+			case "be":
+				f.le = false
+			case "le":
+				f.le = true
+			default:
+				return f, fmt.Errorf("invalid byte order %q, need be or le", v)
+			}
+		default:
+			return f, fmt.Errorf("unknown tag key %q", k)
+		}
+	}
+
+	if !hasreg {
+		return f, errors.New("tag lacks a register")
+	}
+	if f.reg+f.n > 256 {
+		return f, fmt.Errorf("registers %#02x to %#02x exceed the register space", f.reg, f.reg+f.n-1)
+	}
+
+	return f, nil
+}
+
+// ReadInto reads the registers described by the i2c tags of the fields
+// of the struct pointed to by v into these fields. A tag has the form
+//
+// 		`i2c:"reg=0x10,len=2,order=le"`
+//
+// reg is the first register of the field. len is the number of
+// consecutive registers making up the field's value, it defaults to
+// the size of the field's type. order is the byte order of multi
+// register values, be (the default) or le. Fields may be signed or
+// unsigned integers or byte arrays; for signed integers shorter
+// than their type, the value is sign extended. Fields without a tag
+// are ignored.
+//
+// Registers of fields which are adjacent or overlap are read in a
+// single transaction.
+func (d *RegDev) ReadInto(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("RegDev.ReadInto: need a pointer to a struct")
+	}
+	sv := rv.Elem()
+	st := sv.Type()
+
+	var fields []regField
+	for i := 0; i < st.NumField(); i++ {
+		sf := st.Field(i)
+		tag, ok := sf.Tag.Lookup("i2c")
+		if !ok {
+			continue
+		}
+		if sf.PkgPath != "" {
+			return fmt.Errorf("RegDev.ReadInto: field %s is tagged but not exported", sf.Name)
+		}
+
+		var size uint
+		switch sf.Type.Kind() {
+		case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			size = uint(sf.Type.Size())
+		case reflect.Array:
+			if sf.Type.Elem().Kind() != reflect.Uint8 {
+				return fmt.Errorf("RegDev.ReadInto: field %s: arrays need to be byte arrays", sf.Name)
+			}
+			size = uint(sf.Type.Len())
+		default:
+			return fmt.Errorf("RegDev.ReadInto: field %s: unsupported type %s", sf.Name, sf.Type)
+		}
+
+		f, err := parseRegTag(tag, size)
+		if err != nil {
+			return fmt.Errorf("RegDev.ReadInto: field %s: %v", sf.Name, err)
+		}
+		if f.n > size {
+			return fmt.Errorf("RegDev.ReadInto: field %s: %d registers do not fit into %s", sf.Name, f.n, sf.Type)
+		}
+		if sf.Type.Kind() == reflect.Array && f.n != size {
+			return fmt.Errorf("RegDev.ReadInto: field %s: length %d differs from array length %d", sf.Name, f.n, size)
+		}
+		f.index = i
+		fields = append(fields, f)
+	}
+
+	// read coalesced register ranges
+	sorted := make([]regField, len(fields))
+	copy(sorted, fields)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].reg < sorted[j].reg })
+
+	var regs [256]byte
+	for i := 0; i < len(sorted); {
+		start, end := sorted[i].reg, sorted[i].reg+sorted[i].n
+		j := i + 1
+		for ; j < len(sorted) && sorted[j].reg <= end; j++ {
+			if e := sorted[j].reg + sorted[j].n; e > end {
+				end = e
+			}
+		}
+
+		if err := d.ReadRegs(uint8(start), regs[start:end]); err != nil {
+			return err
+		}
+		i = j
+	}
+
+	for _, f := range fields {
+		b := regs[f.reg : f.reg+f.n]
+		fv := sv.Field(f.index)
+
+		if fv.Kind() == reflect.Array {
+			reflect.Copy(fv, reflect.ValueOf(b))
+			continue
+		}
+
+		var u uint64
+		for i := range b {
+			if f.le {
+				u |= uint64(b[i]) << (8 * uint(i))
+			} else {
+				u = u<<8 | uint64(b[i])
+			}
+		}
+
+		switch fv.Kind() {
+		case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			// sign extend from the number of bits read
+			shift := 64 - 8*f.n
+			fv.SetInt(int64(u<<shift) >> shift)
+		default:
+			fv.SetUint(u)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,178 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func countTransactions(log []i2cItem) int {
+	n := 0
+	for _, e := range log {
+		if e.typ == t_STOP {
+			n++
+		}
+	}
+	return n
+}
+
+func TestRegDevReadInto(t *testing.T) {
+	md256 := newmemdev256(Addr7(0x50))
+	copy(md256.mem[0:], []byte{0x12, 0xfe, 0xff, 0x01, 0x02, 0x03})
+	copy(md256.mem[0x10:], []byte{0x80, 0x00, 0xaa, 0xbb})
+	m := NewRecordingMaster(md256)
+	d := NewRegDev(m, Addr7(0x50))
+
+	var v struct {
+		A uint8   `i2c:"reg=0x00"`
+		B int16   `i2c:"reg=0x01,len=2,order=le"`
+		C uint32  `i2c:"reg=0x03,len=3"`
+		D int16   `i2c:"reg=0x10"`
+		E [2]byte `i2c:"reg=0x12,len=2"`
+		F int32   `i2c:"reg=0x10,len=1"`
+		X int
+	}
+
+	if err := d.ReadInto(&v); err != nil {
+		t.Fatalf("ReadInto failed: %v", err)
+	}
+
+	if v.A != 0x12 || v.B != -2 || v.C != 0x010203 || v.D != -0x8000 || v.E != [2]byte{0xaa, 0xbb} || v.F != -0x80 {
+		t.Fatalf("unexpected values read: %+v", v)
+	}
+
+	// registers 0x00-0x05 and 0x10-0x13 are read in one transaction each
+	if n := countTransactions(recorded(m)); n != 2 {
+		t.Fatalf("expected 2 transactions, got %d", n)
+	}
+}
+
+func TestRegDevReadIntoMalformed(t *testing.T) {
+	d := NewRegDev(newmemdev256(Addr7(0x50)), Addr7(0x50))
+
+	cases := []interface{}{
+		&struct {
+			A uint8 `i2c:"len=1"`
+		}{},
+		&struct {
+			A uint8 `i2c:"reg=0x100"`
+		}{},
+		&struct {
+			A uint8 `i2c:"reg=0x00,len=2"`
+		}{},
+		&struct {
+			A uint16 `i2c:"reg=0x00,order=middle"`
+		}{},
+		&struct {
+			A uint16 `i2c:"reg=0x00,foo=1"`
+		}{},
+		&struct {
+			A uint32 `i2c:"reg=0xfe"`
+		}{},
+		&struct {
+			A string `i2c:"reg=0x00"`
+		}{},
+		&struct {
+			a uint8 `i2c:"reg=0x00"`
+		}{},
+		struct{}{},
+	}
+
+	for i, c := range cases {
+		if err := d.ReadInto(c); err == nil {
+			t.Errorf("case %d: ReadInto accepted %#v", i, c)
+		}
+	}
+}
+
+func TestRegDevField(t *testing.T) {
+	md256 := newmemdev256(Addr7(0x50))
+	md256.mem[0x20] = 0xb6 // 1011 0110
+	d := NewRegDev(md256, Addr7(0x50))
+
+	reads := []struct {
+		lsb, width int
+		exp        uint8
+	}{
+		{0, 1, 0x00},
+		{1, 2, 0x03},
+		{2, 3, 0x05},
+		{4, 4, 0x0b},
+		{7, 1, 0x01},
+		{0, 8, 0xb6},
+	}
+	for i, c := range reads {
+		v, err := d.ReadField(0x20, c.lsb, c.width)
+		if err != nil || v != c.exp {
+			t.Errorf("read case %d: expected %#02x, got %#02x, %v", i, c.exp, v, err)
+		}
+	}
+
+	writes := []struct {
+		lsb, width int
+		val        uint8
+		exp        uint8
+	}{
+		{0, 1, 0x01, 0xb7},
+		{1, 2, 0x00, 0xb0},
+		{4, 4, 0x05, 0x56},
+		{7, 1, 0x00, 0x36},
+		{0, 8, 0x5a, 0x5a},
+	}
+	for i, c := range writes {
+		md256.mem[0x20] = 0xb6
+		if err := d.WriteField(0x20, c.lsb, c.width, c.val); err != nil {
+			t.Errorf("write case %d: WriteField failed: %v", i, err)
+			continue
+		}
+		if md256.mem[0x20] != c.exp {
+			t.Errorf("write case %d: expected register %#02x, got %#02x", i, c.exp, md256.mem[0x20])
+		}
+	}
+
+	if _, err := d.ReadField(0x20, 5, 4); err == nil {
+		t.Error("ReadField accepted a field beyond bit 7")
+	}
+	if _, err := d.ReadField(0x20, 0, 0); err == nil {
+		t.Error("ReadField accepted an empty field")
+	}
+	if err := d.WriteField(0x20, 2, 2, 0x04); err == nil {
+		t.Error("WriteField accepted a value wider than the field")
+	}
+}
+
+func TestRegDevReadScaled(t *testing.T) {
+	md256 := newmemdev256(Addr7(0x48))
+	// LM75 style temperature, -25.5 degrees left aligned in 16 bits
+	copy(md256.mem[0x00:], []byte{0xe6, 0x80})
+	copy(md256.mem[0x10:], []byte{0x34, 0x12})
+	md256.mem[0x20] = 0xff
+	d := NewRegDev(md256, Addr7(0x48))
+
+	cases := []struct {
+		reg   uint8
+		n     int
+		s     Scaler
+		order binary.ByteOrder
+		exp   float64
+	}{
+		{0x00, 2, Scaler{Scale: 1.0 / 256, Signed: true}, binary.BigEndian, -25.5},
+		{0x10, 2, Scaler{Scale: 1}, binary.LittleEndian, 0x1234},
+		{0x10, 2, Scaler{Scale: 1}, binary.BigEndian, 0x3412},
+		{0x20, 1, Scaler{Scale: 2, Offset: 1}, nil, 511},
+		{0x20, 1, Scaler{Scale: 2, Offset: 1, Signed: true}, nil, -1},
+	}
+	for i, c := range cases {
+		v, err := d.ReadScaled(c.reg, c.n, c.s, c.order)
+		if err != nil || v != c.exp {
+			t.Errorf("case %d: expected %v, got %v, %v", i, c.exp, v, err)
+		}
+	}
+
+	if _, err := d.ReadScaled(0, 3, Scaler{Scale: 1}, binary.BigEndian); err == nil {
+		t.Error("ReadScaled accepted a 3 byte value")
+	}
+}
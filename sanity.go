@@ -0,0 +1,120 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrBusProtocolViolation signals that a caller of an I2CMaster created
+// by NewBusSanityMaster attempted an operation which is illegal in the
+// current bus state. The errors returned wrap ErrBusProtocolViolation
+// and describe the violation, use errors.Is to test for it.
+var ErrBusProtocolViolation = errors.New("I2C bus protocol violation")
+
+const (
+	sanityIdle = iota
+	sanityStarted
+	sanityWriting
+	sanityReading
+)
+
+type sanityMaster struct {
+	m       I2CMaster
+	state   int
+	lastack bool
+}
+
+// NewBusSanityMaster wraps m in an I2CMaster which checks that the bus
+// protocol is followed before passing operations on to m. Illegal
+// operations, such as a stop condition on an idle bus, reading from a
+// device addressed for writing or sending a stop condition after a
+// read with ACK, are not passed on to m. Instead an error wrapping
+// ErrBusProtocolViolation is returned. The checks only keep a little
+// state and are cheap enough to be left enabled in integration tests
+// on real hardware.
+func NewBusSanityMaster(m I2CMaster) I2CMaster {
+	return &sanityMaster{m: m}
+}
+
+func violation(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrBusProtocolViolation, fmt.Sprintf(format, args...))
+}
+
+func (s *sanityMaster) checkLastAck(op string) error {
+	if s.state == sanityReading && s.lastack {
+		return violation("%s after the last byte was read with an ACK", op)
+	}
+	return nil
+}
+
+func (s *sanityMaster) Start() error {
+	if err := s.checkLastAck("start"); err != nil {
+		return err
+	}
+
+	err := s.m.Start()
+	if err == nil {
+		s.state = sanityStarted
+		s.lastack = false
+	}
+	return err
+}
+
+func (s *sanityMaster) Stop() error {
+	switch s.state {
+	case sanityIdle:
+		return violation("stop on idle bus")
+	case sanityStarted:
+		return violation("stop right after start")
+	}
+	if err := s.checkLastAck("stop"); err != nil {
+		return err
+	}
+
+	err := s.m.Stop()
+	s.state = sanityIdle
+	s.lastack = false
+	return err
+}
+
+func (s *sanityMaster) ReadByte(ack bool) (byte, error) {
+	switch s.state {
+	case sanityIdle:
+		return 0, violation("read on idle bus")
+	case sanityStarted:
+		return 0, violation("read before a device was addressed")
+	case sanityWriting:
+		return 0, violation("read from a device addressed for writing")
+	}
+
+	b, err := s.m.ReadByte(ack)
+	if err == nil {
+		s.lastack = ack
+	}
+	return b, err
+}
+
+func (s *sanityMaster) WriteByte(b byte) error {
+	switch s.state {
+	case sanityIdle:
+		return violation("write on idle bus")
+	case sanityReading:
+		return violation("write to a device addressed for reading")
+	}
+
+	err := s.m.WriteByte(b)
+	if s.state == sanityStarted {
+		// the first byte after a start is the address byte, its
+		// R/W bit determines the direction
+		if b&0x01 != 0 {
+			s.state = sanityReading
+		} else {
+			s.state = sanityWriting
+		}
+	}
+	return err
+}
@@ -0,0 +1,61 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBusSanityMaster(t *testing.T) {
+	// legal transactions pass
+	m := NewBusSanityMaster(newmemdev256(Addr7(0x50)))
+	rb := make([]byte, 2)
+	if _, _, err := NewTransact8x8(m).Transact8x8(Addr7(0x50), 0x10, []byte{1}, rb); err != nil {
+		t.Fatalf("legal transaction failed: %v", err)
+	}
+
+	cases := []struct {
+		name string
+		ops  func(m I2CMaster) error
+	}{
+		{"stop on idle bus", func(m I2CMaster) error {
+			return m.Stop()
+		}},
+		{"write on idle bus", func(m I2CMaster) error {
+			return m.WriteByte(0xa0)
+		}},
+		{"stop after start", func(m I2CMaster) error {
+			m.Start()
+			return m.Stop()
+		}},
+		{"read in write mode", func(m I2CMaster) error {
+			m.Start()
+			m.WriteByte(0xa0)
+			_, err := m.ReadByte(false)
+			return err
+		}},
+		{"write in read mode", func(m I2CMaster) error {
+			m.Start()
+			m.WriteByte(0xa1)
+			return m.WriteByte(0x00)
+		}},
+		{"stop after read with ack", func(m I2CMaster) error {
+			m.Start()
+			m.WriteByte(0xa1)
+			m.ReadByte(true)
+			return m.Stop()
+		}},
+	}
+
+	for _, c := range cases {
+		// scriptedMaster never panics, so violations would go unnoticed
+		// if they were passed on
+		m := NewBusSanityMaster(&scriptedMaster{rd: []byte{0}})
+		if err := c.ops(m); !errors.Is(err, ErrBusProtocolViolation) {
+			t.Errorf("%s: expected ErrBusProtocolViolation, got %v", c.name, err)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// SPD memory types as found in byte 2 of the serial presence detect data.
+const (
+	SPD_DDR  = 0x07
+	SPD_DDR2 = 0x08
+	SPD_DDR3 = 0x0b
+	SPD_DDR4 = 0x0c
+)
+
+// SPDInfo holds the basic fields of a memory module's serial presence
+// detect (SPD) data.
+type SPDInfo struct {
+	Type         uint8  // memory type, see the SPD_* constants
+	Bytes        int    // number of bytes used by the module manufacturer
+	Manufacturer uint16 // JEP-106 manufacturer id: continuation code count with parity in the high byte, id in the low byte
+	CapacityMB   uint64 // module capacity in megabytes
+}
+
+// DecodeSPD reads the SPD data stored in the first 128 bytes of e and
+// decodes its basic fields. DDR2 and DDR3 modules are supported. The
+// checksum (DDR2) or CRC (DDR3) of the data is verified. DecodeSPD
+// moves e's file pointer.
+func DecodeSPD(e EEPROM24) (*SPDInfo, error) {
+	if _, err := e.Seek(0, 0); err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 128)
+	if _, err := io.ReadFull(e, b); err != nil {
+		return nil, err
+	}
+
+	switch b[2] {
+	case SPD_DDR2:
+		return decodeSPDDDR2(b)
+	case SPD_DDR3:
+		return decodeSPDDDR3(b)
+	}
+
+	return nil, fmt.Errorf("DecodeSPD: unsupported memory type %#02x", b[2])
+}
+
+func decodeSPDDDR2(b []byte) (*SPDInfo, error) {
+	var sum uint8
+	for _, v := range b[0:63] {
+		sum += v
+	}
+	if sum != b[63] {
+		return nil, fmt.Errorf("DecodeSPD: checksum mismatch, computed %#02x, stored %#02x", sum, b[63])
+	}
+
+	var info SPDInfo
+	info.Type = b[2]
+	info.Bytes = int(b[0])
+
+	// manufacturer id is preceded by a 0x7f continuation code for every
+	// JEP-106 bank after the first one
+	ncont := uint8(0)
+	for _, v := range b[64:72] {
+		if v != 0x7f {
+			info.Manufacturer = uint16(jep106Parity(ncont))<<8 | uint16(v)
+			break
+		}
+		ncont++
+	}
+
+	// byte 31 is a bitmap of the rank density, bits 0-4 are 1 GB to
+	// 16 GB, bits 5-7 128 MB to 512 MB
+	var density uint64
+	for i := uint(0); i < 8; i++ {
+		if b[31]&(1<<i) != 0 {
+			if i < 5 {
+				density = 1024 << i
+			} else {
+				density = 128 << (i - 5)
+			}
+			break
+		}
+	}
+	ranks := uint64(b[5]&0x07) + 1
+	info.CapacityMB = ranks * density
+
+	return &info, nil
+}
+
+func decodeSPDDDR3(b []byte) (*SPDInfo, error) {
+	// bit 7 of byte 0 selects whether the crc covers bytes 0-116 or 0-125
+	crcend := 126
+	if b[0]&0x80 != 0 {
+		crcend = 117
+	}
+	crc := crc16(b[0:crcend])
+	if stored := uint16(b[126]) | uint16(b[127])<<8; crc != stored {
+		return nil, fmt.Errorf("DecodeSPD: CRC mismatch, computed %#04x, stored %#04x", crc, stored)
+	}
+
+	var info SPDInfo
+	info.Type = b[2]
+
+	switch b[0] & 0x0f {
+	case 1:
+		info.Bytes = 128
+	case 2:
+		info.Bytes = 176
+	case 3:
+		info.Bytes = 256
+	}
+
+	info.Manufacturer = uint16(b[117])<<8 | uint16(b[118])
+
+	sdramMbit := uint64(256) << (b[4] & 0x0f)
+	buswidth := uint64(8) << (b[8] & 0x07)
+	devwidth := uint64(4) << (b[7] & 0x07)
+	ranks := uint64((b[7]>>3)&0x07) + 1
+	info.CapacityMB = sdramMbit / 8 * buswidth / devwidth * ranks
+
+	return &info, nil
+}
+
+// jep106Parity sets the odd parity bit on a JEP-106 code.
+func jep106Parity(v uint8) uint8 {
+	v &= 0x7f
+	if bits.OnesCount8(v)%2 == 0 {
+		v |= 0x80
+	}
+	return v
+}
+
+// crc16 computes the CRC-16/XMODEM checksum of b, as used by DDR3 SPD
+// data: polynomial 0x1021, initial value 0.
+func crc16(b []byte) uint16 {
+	var crc uint16
+	for _, v := range b {
+		crc ^= uint16(v) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
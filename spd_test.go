@@ -0,0 +1,78 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"testing"
+)
+
+// first 128 bytes of the SPD EEPROM of a 4 GB dual rank DDR3-1333
+// SO-DIMM built from 2 Gbit x8 chips
+var spdDDR3Image = []byte{
+	0x92, 0x11, 0x0b, 0x03, 0x03, 0x11, 0x00, 0x09, 0x03, 0x52, 0x01, 0x08, 0x0c, 0x00, 0x3e, 0x00,
+	0x69, 0x78, 0x69, 0x30, 0x69, 0x11, 0x20, 0x89, 0x00, 0x05, 0x3c, 0x3c, 0x00, 0xf0, 0x83, 0x01,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0f, 0x11, 0x21, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x80, 0xce, 0x02, 0x11, 0x01, 0x00, 0x00, 0x00, 0x00, 0xac, 0x1e,
+}
+
+func spdEEPROM(t *testing.T, image []byte) EEPROM24 {
+	conf := Conf_24C02
+	pvt := newPVT24(conf, t)
+	copy(pvt.mem, image)
+	ee := newTestEEPROM24(t, conf, pvt)
+	return ee
+}
+
+func TestDecodeSPDDDR3(t *testing.T) {
+	info, err := DecodeSPD(spdEEPROM(t, spdDDR3Image))
+	if err != nil {
+		t.Fatalf("DecodeSPD failed: %v", err)
+	}
+
+	exp := SPDInfo{Type: SPD_DDR3, Bytes: 176, Manufacturer: 0x80ce, CapacityMB: 4096}
+	if *info != exp {
+		t.Fatalf("expected %#v, got %#v", exp, *info)
+	}
+
+	// a corrupted image must fail the crc check
+	image := append([]byte(nil), spdDDR3Image...)
+	image[4]++
+	if _, err := DecodeSPD(spdEEPROM(t, image)); err == nil {
+		t.Fatalf("DecodeSPD did not detect the corrupted image")
+	}
+}
+
+func TestDecodeSPDDDR2(t *testing.T) {
+	image := make([]byte, 128)
+	image[0] = 0x80
+	image[1] = 0x08
+	image[2] = SPD_DDR2
+	image[5] = 0x61 // 2 ranks
+	image[31] = 0x01
+	image[63] = 0xf2 // checksum
+	image[64] = 0x7f // one continuation code
+	image[65] = 0x98
+
+	info, err := DecodeSPD(spdEEPROM(t, image))
+	if err != nil {
+		t.Fatalf("DecodeSPD failed: %v", err)
+	}
+
+	exp := SPDInfo{Type: SPD_DDR2, Bytes: 128, Manufacturer: 0x0198, CapacityMB: 2048}
+	if *info != exp {
+		t.Fatalf("expected %#v, got %#v", exp, *info)
+	}
+}
+
+func TestDecodeSPDUnsupported(t *testing.T) {
+	image := make([]byte, 128)
+	if _, err := DecodeSPD(spdEEPROM(t, image)); err == nil {
+		t.Fatalf("DecodeSPD accepted an image of unknown memory type")
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"errors"
+	"io"
+)
+
+// pager is implemented by EEPROM24s which know their page size.
+type pager interface {
+	pageSize() uint
+}
+
+type stripedEEPROM24 struct {
+	chips []EEPROM24
+	page  int64 // page size of the chips
+	size  int64 // size of the combined array
+	p     int64 // file pointer
+}
+
+// NewStripedEEPROM24 combines chips into one EEPROM24 whose pages are
+// distributed across the chips round-robin: page n of the combined
+// array is page n/len(chips) of chip n%len(chips). Writes of
+// consecutive pages thus wear all chips evenly. The chips need to be
+// EEPROM24s returned by NewEEPROM24 of identical size and page size.
+// The chips are accessed by ReadAt and WriteAt, their file pointers
+// are not used. Close closes all chips.
+func NewStripedEEPROM24(chips []EEPROM24) (EEPROM24, error) {
+	if len(chips) == 0 {
+		return nil, errors.New("NewStripedEEPROM24: no chips")
+	}
+
+	var s stripedEEPROM24
+	for i, c := range chips {
+		sz, ok1 := c.(Sizer)
+		pg, ok2 := c.(pager)
+		if !ok1 || !ok2 {
+			return nil, errors.New("NewStripedEEPROM24: chips need to be created by NewEEPROM24")
+		}
+		if i == 0 {
+			s.size, s.page = sz.Size(), int64(pg.pageSize())
+		} else if sz.Size() != s.size || int64(pg.pageSize()) != s.page {
+			return nil, errors.New("NewStripedEEPROM24: chips differ in size or page size")
+		}
+	}
+
+	s.chips = chips
+	s.size *= int64(len(chips))
+	return &s, nil
+}
+
+// locate returns the chip holding position pos of the combined array,
+// the corresponding position on the chip and the number of bytes up to
+// the end of the page.
+func (s *stripedEEPROM24) locate(pos int64) (chip EEPROM24, off int64, n int64) {
+	page, aip := pos/s.page, pos%s.page
+	nchips := int64(len(s.chips))
+	return s.chips[page%nchips], (page/nchips)*s.page + aip, s.page - aip
+}
+
+// transferAt reads or writes b at position pos, split into page sized
+// chunks on the respective chips.
+func (s *stripedEEPROM24) transferAt(b []byte, pos int64, write bool) (int, error) {
+	if pos >= s.size && len(b) > 0 {
+		return 0, io.EOF
+	}
+
+	done := 0
+	for done < len(b) && pos < s.size {
+		chip, off, n := s.locate(pos)
+		chunk := b[done:]
+		if int64(len(chunk)) > n {
+			chunk = chunk[:n]
+		}
+
+		var m int
+		var err error
+		if write {
+			m, err = chip.WriteAt(chunk, off)
+		} else {
+			m, err = chip.ReadAt(chunk, off)
+		}
+		done += m
+		pos += int64(m)
+		if err != nil {
+			return done, err
+		}
+	}
+
+	if done < len(b) {
+		return done, io.EOF
+	}
+	return done, nil
+}
+
+func (s *stripedEEPROM24) Read(b []byte) (int, error) {
+	n, err := s.transferAt(b, s.p, false)
+	s.p += int64(n)
+	if err == io.EOF && n > 0 {
+		// like a file, the end is reported by the next Read
+		err = nil
+	}
+	return n, err
+}
+
+func (s *stripedEEPROM24) Write(b []byte) (int, error) {
+	n, err := s.transferAt(b, s.p, true)
+	s.p += int64(n)
+	return n, err
+}
+
+func (s *stripedEEPROM24) ReadAt(b []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("StripedEEPROM24.ReadAt: negative offset")
+	}
+	return s.transferAt(b, off, false)
+}
+
+func (s *stripedEEPROM24) WriteAt(b []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("StripedEEPROM24.WriteAt: negative offset")
+	}
+	return s.transferAt(b, off, true)
+}
+
+func (s *stripedEEPROM24) Seek(offset int64, whence int) (int64, error) {
+	var np int64
+	switch whence {
+	case 0:
+		np = offset
+	case 1:
+		np = s.p + offset
+	case 2:
+		np = s.size + offset
+	default:
+		return 0, errors.New("StripedEEPROM24.Seek: invalid whence")
+	}
+
+	if np < 0 || np > s.size {
+		return 0, errors.New("StripedEEPROM24.Seek: position outside of the array")
+	}
+
+	s.p = np
+	return np, nil
+}
+
+func (s *stripedEEPROM24) Tell() int64 {
+	return s.p
+}
+
+// Size implements Sizer.
+func (s *stripedEEPROM24) Size() int64 {
+	return s.size
+}
+
+// Sync implements Syncer by syncing all chips which are Syncers.
+func (s *stripedEEPROM24) Sync() error {
+	for _, c := range s.chips {
+		if sy, ok := c.(Syncer); ok {
+			if err := sy.Sync(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *stripedEEPROM24) Close() error {
+	var first error
+	for _, c := range s.chips {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,121 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"io"
+	"testing"
+)
+
+func TestStripedEEPROM24(t *testing.T) {
+	conf := EEPROM24Config{Size: 256, PageSize: 8}
+	c0, mem0 := NewFakeEEPROM24(conf)
+	c1, mem1 := NewFakeEEPROM24(conf)
+	c2, mem2 := NewFakeEEPROM24(conf)
+
+	s, err := NewStripedEEPROM24([]EEPROM24{c0, c1, c2})
+	if err != nil {
+		t.Fatalf("NewStripedEEPROM24 failed: %v", err)
+	}
+	if size, err := eepromSize(s); size != 3*256 || err != nil {
+		t.Fatalf("expected a size of %d, got %d, %v", 3*256, size, err)
+	}
+
+	// from the middle of page 0 to the middle of page 4
+	b := make([]byte, 32)
+	fillPattern(b, 0x77)
+	s.Seek(4, 0)
+	if n, err := s.Write(b); n != len(b) || err != nil {
+		t.Fatalf("expected to write %d bytes, got %d, %v", len(b), n, err)
+	}
+
+	if string(mem0[4:8]) != string(b[0:4]) || // page 0
+		string(mem1[0:8]) != string(b[4:12]) || // page 1
+		string(mem2[0:8]) != string(b[12:20]) || // page 2
+		string(mem0[8:16]) != string(b[20:28]) || // page 3
+		string(mem1[8:12]) != string(b[28:32]) { // page 4
+		t.Fatalf("data not striped across the chips")
+	}
+
+	r := make([]byte, len(b))
+	s.Seek(4, 0)
+	if n, err := io.ReadFull(s, r); n != len(r) || err != nil {
+		t.Fatalf("expected to read %d bytes, got %d, %v", len(r), n, err)
+	}
+	if string(r) != string(b) {
+		t.Fatalf("read % x, expected % x", r, b)
+	}
+
+	// ReadAt reads the same without moving the file pointer
+	for i := range r {
+		r[i] = 0
+	}
+	if n, err := s.ReadAt(r, 4); n != len(r) || err != nil || string(r) != string(b) {
+		t.Fatalf("ReadAt: expected % x, got % x, %d, %v", b, r, n, err)
+	}
+	if p := s.Tell(); p != 4+int64(len(r)) {
+		t.Fatalf("ReadAt moved the file pointer to %d", p)
+	}
+
+	// the last page is on the last chip
+	s.Seek(-2, 2)
+	if n, err := s.Write([]byte{1, 2, 3}); n != 2 || err != io.EOF {
+		t.Fatalf("expected to write 2 bytes and io.EOF, got %d, %v", n, err)
+	}
+	if mem2[254] != 1 || mem2[255] != 2 {
+		t.Fatalf("last bytes not written to the last chip")
+	}
+	if n, err := s.Read(r); n != 0 || err != io.EOF {
+		t.Fatalf("expected io.EOF at the end, got %d, %v", n, err)
+	}
+
+	other, _ := NewFakeEEPROM24(EEPROM24Config{Size: 256, PageSize: 16})
+	if _, err := NewStripedEEPROM24([]EEPROM24{c0, other}); err == nil {
+		t.Fatalf("NewStripedEEPROM24 accepted chips with different page sizes")
+	}
+}
+
+// TestEEPROM24Seek checks that the EEPROM24 implementations agree on
+// Seek, which returns the new position like io.Seeker.
+func TestEEPROM24Seek(t *testing.T) {
+	conf := EEPROM24Config{Size: 256, PageSize: 8}
+	c0, _ := NewFakeEEPROM24(conf)
+	c1, _ := NewFakeEEPROM24(conf)
+	s, err := NewStripedEEPROM24([]EEPROM24{c0, c1})
+	if err != nil {
+		t.Fatalf("NewStripedEEPROM24 failed: %v", err)
+	}
+	ee, _ := NewFakeEEPROM24(conf)
+
+	for name, e := range map[string]EEPROM24{"ee24": ee, "striped": s} {
+		size, _ := eepromSize(e)
+		cases := []struct {
+			offset int64
+			whence int
+			exp    int64
+		}{
+			{10, 0, 10},
+			{5, 1, 15},
+			{-6, 2, size - 6},
+		}
+		for _, c := range cases {
+			if p, err := e.Seek(c.offset, c.whence); p != c.exp || err != nil {
+				t.Errorf("%s: Seek(%d, %d): expected %d, got %d, %v", name, c.offset, c.whence, c.exp, p, err)
+			}
+		}
+
+		for _, c := range []struct {
+			offset int64
+			whence int
+		}{{-1, 0}, {1, 2}, {0, 3}} {
+			if p, err := e.Seek(c.offset, c.whence); p != 0 || err == nil {
+				t.Errorf("%s: Seek(%d, %d): expected 0 and an error, got %d, %v", name, c.offset, c.whence, p, err)
+			}
+		}
+		if p := e.Tell(); p != size-6 {
+			t.Errorf("%s: failed seeks moved the file pointer to %d", name, p)
+		}
+	}
+}
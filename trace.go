@@ -0,0 +1,137 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// TraceOp is the operation of a TraceEntry.
+type TraceOp int
+
+const (
+	TraceStart TraceOp = iota
+	TraceStop
+	TraceRead
+	TraceWrite
+)
+
+// TraceEntry is an operation carried out by a RecordingMaster.
+type TraceEntry struct {
+	Time time.Time // when the operation completed
+	Op   TraceOp
+	Byte byte  // byte read or written
+	ACK  bool  // for reads, whether the byte was ACKed
+	Err  error // error returned by the operation
+
+	// CorrelationID is the correlation ID of the context of the
+	// transaction, c.f. WithCorrelationID, or empty.
+	CorrelationID string
+}
+
+func (e TraceEntry) String() string {
+	ts := e.Time.Format("15:04:05.000000")
+	if e.CorrelationID != "" {
+		ts += " [" + e.CorrelationID + "]"
+	}
+	switch e.Op {
+	case TraceStart:
+		return fmt.Sprintf("%s START > %T: %#v", ts, e.Err, e.Err)
+	case TraceStop:
+		return fmt.Sprintf("%s STOP > %T: %#v", ts, e.Err, e.Err)
+	case TraceRead:
+		return fmt.Sprintf("%s READ > %#02x ack %v @ %T: %#v", ts, e.Byte, e.ACK, e.Err, e.Err)
+	case TraceWrite:
+		return fmt.Sprintf("%s WRITE %#02x > %T: %#v", ts, e.Byte, e.Err, e.Err)
+	}
+
+	return fmt.Sprintf("%s unknown TraceOp %d", ts, e.Op)
+}
+
+// RecordingMaster is an I2CMaster which carries out all operations on
+// the embedded I2CMaster and records them with a timestamp, e.g. to
+// capture a trace for a bug report. Results and errors are passed on
+// unchanged. The trace can be retrieved by Log while operations are
+// carried out from another goroutine.
+type RecordingMaster struct {
+	I2CMaster
+
+	mu   sync.Mutex
+	log  []TraceEntry
+	corr string // correlation ID of the current transaction
+}
+
+// NewRecordingMaster returns a RecordingMaster carrying out operations
+// on m.
+func NewRecordingMaster(m I2CMaster) *RecordingMaster {
+	return &RecordingMaster{I2CMaster: m}
+}
+
+func (r *RecordingMaster) record(e TraceEntry) {
+	e.Time = time.Now()
+	r.mu.Lock()
+	e.CorrelationID = r.corr
+	r.log = append(r.log, e)
+	r.mu.Unlock()
+}
+
+func (r *RecordingMaster) correlate(id string) func() {
+	r.mu.Lock()
+	prev := r.corr
+	r.corr = id
+	r.mu.Unlock()
+
+	return func() {
+		r.mu.Lock()
+		r.corr = prev
+		r.mu.Unlock()
+	}
+}
+
+func (r *RecordingMaster) Start() error {
+	err := r.I2CMaster.Start()
+	r.record(TraceEntry{Op: TraceStart, Err: err})
+	return err
+}
+
+func (r *RecordingMaster) Stop() error {
+	err := r.I2CMaster.Stop()
+	r.record(TraceEntry{Op: TraceStop, Err: err})
+	return err
+}
+
+func (r *RecordingMaster) ReadByte(ack bool) (byte, error) {
+	b, err := r.I2CMaster.ReadByte(ack)
+	r.record(TraceEntry{Op: TraceRead, Byte: b, ACK: ack, Err: err})
+	return b, err
+}
+
+func (r *RecordingMaster) WriteByte(b byte) error {
+	err := r.I2CMaster.WriteByte(b)
+	r.record(TraceEntry{Op: TraceWrite, Byte: b, Err: err})
+	return err
+}
+
+// SupportsRepeatedStart implements NoRepeatedStart on behalf of the
+// underlying master.
+func (r *RecordingMaster) SupportsRepeatedStart() bool {
+	return supportsRepeatedStart(r.I2CMaster)
+}
+
+// Log returns a copy of the entries recorded since the last Reset.
+func (r *RecordingMaster) Log() []TraceEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]TraceEntry(nil), r.log...)
+}
+
+// Reset discards the entries recorded so far.
+func (r *RecordingMaster) Reset() {
+	r.mu.Lock()
+	r.log = nil
+	r.mu.Unlock()
+}
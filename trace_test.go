@@ -0,0 +1,43 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRecordingMaster(t *testing.T) {
+	r := NewRecordingMaster(&nackAfter{scriptedMaster{rd: []byte{0x42}}, 1})
+	tr := NewTransact8x8(r)
+
+	if _, _, err := tr.Transact8x8(Addr7(0x50), 0x12, []byte{1}, nil); err != NACKReceived {
+		t.Fatalf("expected NACKReceived to be passed on, got %v", err)
+	}
+	log := r.Log()
+	exp := []TraceEntry{{Op: TraceStart}, {Op: TraceWrite, Byte: 0xa0}, {Op: TraceWrite, Byte: 0x12, Err: NACKReceived}, {Op: TraceStop}}
+	if len(log) != len(exp) {
+		t.Fatalf("expected %d entries, got %v", len(exp), log)
+	}
+	for i, e := range log {
+		if e.Op != exp[i].Op || e.Byte != exp[i].Byte || e.ACK != exp[i].ACK || e.Err != exp[i].Err || e.Time.IsZero() {
+			t.Errorf("entry %d: expected %v, got %v", i, exp[i], e)
+		}
+	}
+	if s := log[2].String(); !strings.HasSuffix(s, " WRITE 0x12 > *errors.errorString: &errors.errorString{s:\"NACK received\"}") {
+		t.Errorf("unexpected entry formatting %q", s)
+	}
+
+	// Log returns a copy
+	log[0].Op = TraceRead
+	if r.Log()[0].Op != TraceStart {
+		t.Errorf("modifying the result of Log modified the trace")
+	}
+
+	r.Reset()
+	if log := r.Log(); len(log) != 0 {
+		t.Errorf("expected an empty trace after Reset, got %v", log)
+	}
+}
@@ -6,8 +6,13 @@ package i2cm
 
 import (
 	"errors"
+	"fmt"
+	"time"
 )
 
+// sleep is replaced in tests to observe delays.
+var sleep = time.Sleep
+
 // Transactor encompasses all implemented I2C bus transaction
 // types.
 type Transactor interface {
@@ -24,12 +29,41 @@ type transactor struct {
 // based on the argument I2CMaster. This is a convenience
 // function which consolidates the results of the 
 // NewTransact*x* family of functions.
-func NewTransactor(m I2CMaster) Transactor {
+//
+// The transactors can be configured with opts. Without options,
+// the results of NewTransact8x8 and NewTransact16x8 are returned.
+// Options which modify the framing of transactions, such as
+// WithInterByteDelay, can only be applied to transactions carried out
+// using the low level I2CMaster interface. If any of them are given,
+// m is used as an I2CMaster even if it implements the transactor
+// interfaces natively.
+func NewTransactor(m I2CMaster, opts ...TransactorOption) Transactor {
+	var c transactorConfig
+	for _, o := range opts {
+		o(&c)
+	}
+
 	var t transactor
-	t.Transactor8x8 = NewTransact8x8(m)
-	t.Transactor16x8 = NewTransact16x8(m)
+	if c.lowlevel {
+		t.Transactor8x8 = NewTransact8x8WithOptions(m, c.opts)
+		t.Transactor16x8 = transactor16x8{t.Transactor8x8}
+	} else {
+		t.Transactor8x8 = NewTransact8x8(m)
+		t.Transactor16x8 = NewTransact16x8(m)
+	}
 
-	return &t
+	var tr Transactor = &t
+	if c.maxNACKs > 0 {
+		tr = &healthTransactor{t: tr, max: c.maxNACKs}
+	}
+	if c.retries > 0 {
+		tr = &retryTransactor{tr, c.retries}
+	}
+	if c.maxReadLen > 0 {
+		tr = &splitReadTransactor{tr, c.maxReadLen}
+	}
+
+	return tr
 }
 
 // Implements a write-then-read transaction with 8 bit register
@@ -45,12 +79,91 @@ func NewTransactor(m I2CMaster) Transactor {
 // 
 // A transaction with len(r) is carried out as follows:
 // 		[S] [(devaddr<<1)] A [regaddr] A [w[0]] A ... [S] [(devaddr<<1)|1] r[0] [A] ... r[len(r)-1] [N] [P]
+//
+// If the I2C master does not support repeated starts, c.f. NoRepeatedStart,
+// the second [S] is replaced by [P] [S].
 type Transactor8x8 interface {
 	Transact8x8(addr Addr, regaddr uint8, w []byte, r []byte) (nw, nr int, err error)
 }
 
 type transactor8x8 struct {
-	m I2CMaster
+	m    I2CMaster
+	opts Transact8x8Options
+}
+
+// Transact8x8Options modify how 8x8 transactions are carried out on
+// the low level I2CMaster interface. The zero value results in the
+// transactions described at Transactor8x8.
+type Transact8x8Options struct {
+	// DummyFirstRead makes the read phase read and discard one byte
+	// before filling r, for devices which deliver a dummy byte right
+	// after being addressed for reading. The discarded byte is not
+	// counted in nr.
+	DummyFirstRead bool
+
+	// NoRepeatedStart separates the write and the read phase by a stop
+	// and a start condition instead of a repeated start condition, as
+	// if the master did not support repeated starts.
+	NoRepeatedStart bool
+
+	// InterByteDelay is waited for after each byte transferred, for
+	// devices which can not keep up with the bus clock.
+	InterByteDelay time.Duration
+
+	// ReadPhaseDelay is waited for once between the write and the read
+	// phase of transactions which read, e.g. for sensors which need
+	// time to convert after being triggered by the register write. If
+	// NoRepeatedStart is set too, the delay is waited for after the
+	// stop condition, leaving the bus free in the meantime.
+	ReadPhaseDelay time.Duration
+
+	// PostStartDelay is waited for after each start and repeated start
+	// condition, before the address byte is sent, for buses on which
+	// the start condition needs time to settle, e.g. on long cables.
+	PostStartDelay time.Duration
+
+	// StopOnNACK treats a NACK of a data byte in the write phase as the
+	// device signalling the end of the data it accepts. The transaction
+	// is ended with a stop condition without a read phase, and nw only
+	// counts the bytes ACKed by the device. The transaction succeeds
+	// unless a read was requested, which fails with ErrReadPhaseSkipped.
+	StopOnNACK bool
+
+	// NACK selects the errors returned for NACKed address bytes.
+	NACK NACKPolicy
+
+	// ByteAtATime carries out each data byte in a transaction of its
+	// own, consisting of start, device address, register address, the
+	// byte and stop, with the register address incremented for each
+	// byte. Reads start at the register address following the bytes
+	// written. This is very slow and only meant for broken devices which
+	// do not handle multi-byte transfers. Only 8 bit register addresses
+	// are supported; 16x8 transactions emulated on top of an 8x8
+	// transactor would send the low register address byte as data.
+	ByteAtATime bool
+
+	// keepOpen omits the stop condition at the end of a successful
+	// transaction, c.f. KeepOpen8x8.
+	keepOpen bool
+}
+
+// NACKPolicy selects the errors returned by transactions when the
+// device NACKs its address, separately for the address preceding the
+// write phase and the one preceding the read phase. A nil error selects
+// NoSuchDevice. NACKReceived keeps the NACK as is, other errors are
+// returned in place of the NACK.
+type NACKPolicy struct {
+	Address     error
+	ReadAddress error
+}
+
+// nackError returns the error for a NACK of an address byte under
+// policy err.
+func nackError(err error) error {
+	if err == nil {
+		return NoSuchDevice
+	}
+	return err
 }
 
 // NewTransact8x8 returns a Transactor8x8 which is based on m.
@@ -62,11 +175,143 @@ func NewTransact8x8(m I2CMaster) Transactor8x8 {
 	if t, ok := m.(Transactor8x8); ok {
 		return t
 	}
-	return transactor8x8{m}
+	return transactor8x8{m: m}
+}
+
+// NewTransact8x8WithOptions returns a Transactor8x8 which carries out
+// transactions using the low level I2CMaster interface of m, modified
+// by opts. In contrast to NewTransact8x8, m is used as an I2CMaster even
+// if it is a Transactor8x8, as the options can not be applied to native
+// transactors.
+func NewTransact8x8WithOptions(m I2CMaster, opts Transact8x8Options) Transactor8x8 {
+	return transactor8x8{m, opts}
 }
 
 func (t transactor8x8) Transact8x8(addr Addr, regaddr uint8, w []byte, r []byte) (int, int, error) {
-	return I2CMasterTransact8x8(t.m, addr, regaddr, w, r)
+	res := transact8x8(t.m, &t.opts, addr, regaddr, w, r)
+	return res.BytesWritten, res.BytesRead, res.Err
+}
+
+func (t transactor8x8) TransactEx8x8(addr Addr, regaddr uint8, w []byte, r []byte) TransactResult {
+	return transact8x8(t.m, &t.opts, addr, regaddr, w, r)
+}
+
+// TransactInPlace8x8 carries out an 8x8 transaction on tr which writes
+// buf and then reads len(buf) bytes into buf, overwriting the data
+// written. The write data is copied before the transaction, so the read
+// phase can not corrupt it. Where the read phase starts is up to the
+// device; many devices continue after the last register written.
+func TransactInPlace8x8(tr Transactor8x8, addr Addr, regaddr uint8, buf []byte) error {
+	w := make([]byte, len(buf))
+	copy(w, buf)
+	_, _, err := tr.Transact8x8(addr, regaddr, w, buf)
+	return err
+}
+
+// EstimateDuration8x8 returns the time an 8x8 transaction writing w and
+// reading r, as described at Transactor8x8, occupies a bus clocked at
+// clockHz. Every byte, including the address and register address
+// bytes, takes 9 clock periods, 8 for the data and one for the ACK. The
+// start, repeated start and stop conditions are accounted for with one
+// clock period each. Clock stretching by the devices and delays
+// introduced by the master are not included.
+func EstimateDuration8x8(clockHz float64, w, r []byte) time.Duration {
+	bytes := 2 + len(w) // address and register address
+	conds := 2          // start and stop
+	if len(r) > 0 {
+		bytes += 1 + len(r) // read address
+		conds++             // repeated start
+	}
+
+	clocks := 9*bytes + conds
+	return time.Duration(float64(clocks) / clockHz * float64(time.Second))
+}
+
+// Phase identifies a part of an I2C transaction.
+type Phase int
+
+const (
+	PhaseUnknown     Phase = iota // the phase could not be determined
+	PhaseStart                    // sending the start condition
+	PhaseAddress                  // addressing the device for writing
+	PhaseRegAddr                  // writing the register address
+	PhaseWrite                    // writing data
+	PhaseRestart                  // sending the repeated start condition
+	PhaseReadAddress              // addressing the device for reading
+	PhaseRead                     // reading data
+	PhaseStop                     // sending the stop condition
+)
+
+var phaseNames = []string{
+	"unknown",
+	"start",
+	"address",
+	"register address",
+	"write",
+	"repeated start",
+	"read address",
+	"read",
+	"stop",
+}
+
+func (p Phase) String() string {
+	if p < 0 || int(p) >= len(phaseNames) {
+		return fmt.Sprintf("Phase(%d)", int(p))
+	}
+	return phaseNames[p]
+}
+
+// TransactResult describes the outcome of a transaction in more detail
+// than the (nw, nr, err) tuple returned by Transact8x8.
+type TransactResult struct {
+	BytesWritten int   // number of data bytes written, excluding addresses
+	BytesRead    int   // number of data bytes read
+	Completed    bool  // true if all phases including the stop finished without error
+	Err          error // the first error encountered, nil if Completed
+	Phase        Phase // the phase in which the transaction ended
+
+	// ByteOps is the number of byte operations carried out on the bus,
+	// including address, register address and discarded bytes, but
+	// excluding start and stop conditions. It is zero for transactions
+	// carried out by native transactors, which do not report it.
+	ByteOps int
+}
+
+// TransactorEx8x8 carries out the same transactions as Transactor8x8
+// but reports the outcome as a TransactResult. Phase is PhaseStop for
+// completed transactions and the phase in which the error occured
+// otherwise.
+type TransactorEx8x8 interface {
+	TransactEx8x8(addr Addr, regaddr uint8, w []byte, r []byte) TransactResult
+}
+
+type transactorEx8x8 struct {
+	tr8x8 Transactor8x8
+}
+
+// NewTransactEx8x8 returns a TransactorEx8x8 which is based on m.
+// If m already is a TransactorEx8x8, it is returned unchanged. If m is
+// a native Transactor8x8, the results of its transactions are converted
+// to TransactResults. As the native transactor does not report where
+// it stopped, Phase is PhaseUnknown for failed transactions.
+func NewTransactEx8x8(m I2CMaster) TransactorEx8x8 {
+	if t, ok := m.(TransactorEx8x8); ok {
+		return t
+	}
+	if t, ok := m.(Transactor8x8); ok {
+		return transactorEx8x8{t}
+	}
+	return transactor8x8{m: m}
+}
+
+func (t transactorEx8x8) TransactEx8x8(addr Addr, regaddr uint8, w []byte, r []byte) TransactResult {
+	nw, nr, err := t.tr8x8.Transact8x8(addr, regaddr, w, r)
+	res := TransactResult{BytesWritten: nw, BytesRead: nr, Err: err}
+	if err == nil {
+		res.Completed = true
+		res.Phase = PhaseStop
+	}
+	return res
 }
 
 // I2CMasterTransact8x8 carries out a transaction as specified by
@@ -74,74 +319,164 @@ func (t transactor8x8) Transact8x8(addr Addr, regaddr uint8, w []byte, r []byte)
 // function can be used as a fallback for implementors of Transactor8x8
 // in case their I2C bus master only supports a limited set of 8x8
 // transactions.
+//
+// 10 bit addresses are sent as [11110 a9 a8 0] A [a7..a0] A in place of
+// [(devaddr<<1)]. In the read phase, the device is addressed by
+// [11110 a9 a8 1] alone, which devices only accept after a repeated
+// start. Reads from 10 bit addresses thus fail on masters without
+// repeated starts.
 func I2CMasterTransact8x8(m I2CMaster, addr Addr, regaddr uint8, w []byte, r []byte) (int, int, error) {
-	nr := 0
-	nw := 0
+	res := I2CMasterTransactEx8x8(m, addr, regaddr, w, r)
+	return res.BytesWritten, res.BytesRead, res.Err
+}
+
+// I2CMasterTransactEx8x8 is like I2CMasterTransact8x8 but returns
+// a TransactResult.
+func I2CMasterTransactEx8x8(m I2CMaster, addr Addr, regaddr uint8, w []byte, r []byte) TransactResult {
+	return transact8x8(m, &Transact8x8Options{}, addr, regaddr, w, r)
+}
+
+func transact8x8(m I2CMaster, opts *Transact8x8Options, addr Addr, regaddr uint8, w []byte, r []byte) TransactResult {
+	if opts.ByteAtATime && len(w)+len(r) > 0 {
+		return transactByteAtATime(m, opts, addr, regaddr, w, r)
+	}
+
+	ptr := [1]byte{regaddr}
+	return transact(m, opts, addr, ptr[:], w, r)
+}
 
-	if addr.GetAddrLen() != 7 {
-		return nw, nr, errors.New("I2CMasterTransact8x8: only 7 bit addresses are supported")
+// transactByteAtATime carries out a transaction as a series of single
+// byte transactions, c.f. Transact8x8Options.ByteAtATime.
+func transactByteAtATime(m I2CMaster, opts *Transact8x8Options, addr Addr, regaddr uint8, w []byte, r []byte) TransactResult {
+	o := *opts
+	o.ByteAtATime = false
+
+	var res TransactResult
+	for i := 0; i < len(w)+len(r); i++ {
+		var sub TransactResult
+		if i < len(w) {
+			sub = transact8x8(m, &o, addr, regaddr+uint8(i), w[i:i+1], nil)
+		} else {
+			sub = transact8x8(m, &o, addr, regaddr+uint8(i), nil, r[i-len(w):i-len(w)+1])
+		}
+
+		res.BytesWritten += sub.BytesWritten
+		res.BytesRead += sub.BytesRead
+		res.ByteOps += sub.ByteOps
+		res.Completed, res.Err, res.Phase = sub.Completed, sub.Err, sub.Phase
+		if sub.Err != nil {
+			break
+		}
+	}
+	return res
+}
+
+// transact carries out a write-then-read transaction in which the
+// device address is followed by the register pointer bytes in ptr,
+// which are not counted as written data.
+func transact(m I2CMaster, opts *Transact8x8Options, addr Addr, ptr []byte, w []byte, r []byte) TransactResult {
+	var res TransactResult
+
+	repstart := !opts.NoRepeatedStart && supportsRepeatedStart(m)
+
+	var frames [3]byte
+	wframe, rframe, err := addrFrames(&frames, addr, len(r) > 0, repstart)
+	if err != nil {
+		res.Err = err
+		return res
 	}
 
+	if opts.InterByteDelay > 0 {
+		m = delayMaster{m, opts.InterByteDelay}
+	}
+	if opts.PostStartDelay > 0 {
+		m = startDelayMaster{m, opts.PostStartDelay}
+	}
+
+	res.Phase = PhaseStart
 	if err := m.Start(); err != nil {
-		return nw, nr, err
+		res.Err = err
+		return res
 	}
 
 	// inner function handles the whole transaction between
 	// but not including the start and the stop bit
-	err := func() error {
+	err = func() error {
 		// address device
-		addrb := uint8(addr.GetBaseAddr() << 1)
-		if err := m.WriteByte(addrb); err != nil {
-			if err == NACKReceived {
-				return NoSuchDevice
+		res.Phase = PhaseAddress
+		for _, b := range wframe {
+			res.ByteOps++
+			if err := m.WriteByte(b); err != nil {
+				if err == NACKReceived {
+					return nackError(opts.NACK.Address)
+				}
+				return err
 			}
-			return err
 		}
 
 		// write regaddr
-		if err := m.WriteByte(regaddr); err != nil {
-			return err
+		res.Phase = PhaseRegAddr
+		for _, b := range ptr {
+			res.ByteOps++
+			if err := m.WriteByte(b); err != nil {
+				return err
+			}
 		}
 
 		// write w
+		res.Phase = PhaseWrite
 		for _, b := range w {
-			if err := m.WriteByte(b); err != nil {
+			res.ByteOps++
+			err := m.WriteByte(b)
+			if err == NACKReceived && opts.StopOnNACK {
+				if len(r) > 0 {
+					return ErrReadPhaseSkipped
+				}
+				return nil
+			}
+			if err != nil {
 				return err
 			}
 
-			nw++
+			res.BytesWritten++
 		}
 
 		// read part of transaction is only performed if desired
 		if len(r) > 0 {
 			// start again
-			if err := m.Start(); err != nil {
-				return err
+			res.Phase = PhaseRestart
+			if err := restart(m, repstart, opts.ReadPhaseDelay); err != nil {
+				return fmt.Errorf("%w: %w", ErrReadPhaseStartFailed, err)
 			}
 
 			// write device's read address
-			if err := m.WriteByte(addrb | 0x01); err != nil {
-				if err == NACKReceived {
-					return NoSuchDevice
+			res.Phase = PhaseReadAddress
+			for _, b := range rframe {
+				res.ByteOps++
+				if err := m.WriteByte(b); err != nil {
+					if err == NACKReceived {
+						return nackError(opts.NACK.ReadAddress)
+					}
+					return err
 				}
-				return err
 			}
 
-			for i := 0; i < len(r); i++ {
-				ack := true
-				if i == len(r)-1 {
-					ack = false
-				}
-				rb, err := m.ReadByte(ack)
-				if err != nil {
+			res.Phase = PhaseRead
+			if opts.DummyFirstRead {
+				res.ByteOps++
+				if _, err := m.ReadByte(true); err != nil {
 					return err
 				}
-
-				r[i] = rb
-
-				nr++
 			}
 
+			nr, err := ReadBlock(m, r, false)
+			res.BytesRead += nr
+			res.ByteOps += nr
+			if err != nil {
+				// the failed read is a byte operation, too
+				res.ByteOps++
+				return err
+			}
 		}
 
 		return nil
@@ -151,11 +486,23 @@ func I2CMasterTransact8x8(m I2CMaster, addr Addr, regaddr uint8, w []byte, r []b
 		// if there already was an error, the error from stop is ignored
 		// and the first error is reported
 		m.Stop()
-	} else {
-		err = m.Stop()
+		res.Err = err
+		return res
 	}
 
-	return nw, nr, err
+	if opts.keepOpen {
+		res.Completed = true
+		return res
+	}
+
+	res.Phase = PhaseStop
+	if err := m.Stop(); err != nil {
+		res.Err = err
+		return res
+	}
+
+	res.Completed = true
+	return res
 }
 
 // Implements a write-then-read transaction with 16 bit register
@@ -194,6 +541,37 @@ func NewTransact16x8(m I2CMaster) Transactor16x8 {
 	return transactor16x8{NewTransact8x8(m)}
 }
 
+// IsEmulated reports whether t carries out 16x8 transactions by
+// emulating them with 8x8 transactions, as the Transactor16x8s returned
+// by NewTransact16x8 for masters which are no Transactor16x8 do. The
+// emulation copies the data written to prepend the low byte of the
+// register address. For the Transactors returned by NewTransactor, their
+// Transactor16x8 is examined. The decorators of this package, such as
+// those added by the options of NewTransactor, RateLimit and Arbitrate,
+// are looked through.
+func IsEmulated(t Transactor16x8) bool {
+	for {
+		switch tr := t.(type) {
+		case transactor16x8:
+			return true
+		case *transactor:
+			t = tr.Transactor16x8
+		case *healthTransactor:
+			t = tr.t
+		case *retryTransactor:
+			t = tr.t
+		case *splitReadTransactor:
+			t = tr.t
+		case *rateLimiter:
+			t = tr.t
+		case *arbitrated:
+			t = tr.t
+		default:
+			return false
+		}
+	}
+}
+
 func (t transactor16x8) Transact16x8(addr Addr, regaddr uint16, w []byte, r []byte) (int, int, error) {
 	// we emulate a 16x8 transaction by doing an 8x8 transaction with hi8(regaddr)
 	// as the "register address" and lo8(regaddr) as the first byte to write
@@ -211,3 +589,178 @@ func (t transactor16x8) Transact16x8(addr Addr, regaddr uint16, w []byte, r []by
 	}
 	return nw, nr, err
 }
+
+// ReadBlock reads len(r) bytes from the device which is currently
+// addressed for reading on m's bus. All bytes but the last one are
+// ACKed. The last byte is ACKed only if ackLast is true, which keeps
+// the device sending so that the caller may continue reading, e.g.
+// with another call to ReadBlock. The last byte read before a stop or
+// repeated start condition must be NACKed. ReadBlock returns the number
+// of bytes read.
+func ReadBlock(m I2CMaster, r []byte, ackLast bool) (int, error) {
+	for i := range r {
+		ack := true
+		if i == len(r)-1 {
+			ack = ackLast
+		}
+		rb, err := m.ReadByte(ack)
+		if err != nil {
+			return i, err
+		}
+
+		r[i] = rb
+	}
+
+	return len(r), nil
+}
+
+// ReadCountPrefixed reads a count prefixed block from register regaddr
+// of the device at addr: the first byte read holds the number of data
+// bytes following it in the same read phase. The data bytes are
+// returned. If the count exceeds maxLen, the read is ended and an error
+// is returned. A NACK of the device address in either phase is reported
+// as NoSuchDevice.
+//
+// As a byte's ACK has to be decided on before the count is known, the
+// count byte is always ACKed. For a count of 0, or one exceeding
+// maxLen, a dummy byte is read and NACKed to end the read phase.
+func ReadCountPrefixed(m I2CMaster, addr Addr, regaddr uint8, maxLen int) ([]byte, error) {
+	repstart := supportsRepeatedStart(m)
+	var frames [3]byte
+	wframe, rframe, err := addrFrames(&frames, addr, true, repstart)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.Start(); err != nil {
+		return nil, err
+	}
+
+	data, err := func() ([]byte, error) {
+		if err := writeAddrFrame(m, wframe); err != nil {
+			return nil, err
+		}
+		if err := m.WriteByte(regaddr); err != nil {
+			return nil, err
+		}
+
+		if err := restart(m, repstart, 0); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrReadPhaseStartFailed, err)
+		}
+		if err := writeAddrFrame(m, rframe); err != nil {
+			return nil, err
+		}
+
+		count, err := m.ReadByte(true)
+		if err != nil {
+			return nil, err
+		}
+		if count == 0 || int(count) > maxLen {
+			if _, err := m.ReadByte(false); err != nil {
+				return nil, err
+			}
+			if int(count) > maxLen {
+				return nil, fmt.Errorf("ReadCountPrefixed: count %d exceeds the maximum of %d", count, maxLen)
+			}
+			return []byte{}, nil
+		}
+
+		data := make([]byte, count)
+		if _, err := ReadBlock(m, data, false); err != nil {
+			return nil, err
+		}
+		return data, nil
+	}()
+
+	if err != nil {
+		// the first error is reported
+		m.Stop()
+		return nil, err
+	}
+
+	if err := m.Stop(); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// writeAddrFrame writes the address bytes in frame. A NACK is reported
+// as NoSuchDevice.
+func writeAddrFrame(m I2CMaster, frame []byte) error {
+	for _, b := range frame {
+		if err := m.WriteByte(b); err != nil {
+			if err == NACKReceived {
+				return NoSuchDevice
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// addrFrames returns the bytes addressing the device at addr in the
+// write phase and in the read phase of a transaction, c.f. AddrFramer.
+// read tells whether the transaction has a read phase, repstart whether
+// it is started by a repeated start, which reads from 10 bit addresses
+// need. The frames are built in buf, sparing the callers an allocation.
+func addrFrames(buf *[3]byte, addr Addr, read, repstart bool) (wframe, rframe []byte, err error) {
+	if f, ok := addr.(AddrFramer); ok {
+		return f.WriteFrame(), f.ReadFrame(), nil
+	}
+
+	switch addr.GetAddrLen() {
+	case 7:
+		buf[0] = uint8(addr.GetBaseAddr() << 1)
+		wframe = buf[:1]
+	case 10:
+		if read && !repstart {
+			return nil, nil, errors.New("I2C transaction: reads from 10 bit addresses need repeated starts")
+		}
+		a := addr.GetBaseAddr()
+		buf[0], buf[1] = 0xf0|uint8(a>>7)&0x06, uint8(a)
+		wframe = buf[:2]
+	default:
+		return nil, nil, errors.New("I2C transaction: only 7 and 10 bit addresses are supported")
+	}
+
+	// the read phase only repeats the first byte with the R/W bit set
+	buf[2] = buf[0] | 0x01
+	return wframe, buf[2:3], nil
+}
+
+// restart separates the write from the read phase of a transaction.
+// It sends a repeated start condition or, if repstart is false, a stop
+// condition followed by a start condition. If delay is positive, it is
+// waited for before the start condition.
+func restart(m I2CMaster, repstart bool, delay time.Duration) error {
+	if !repstart {
+		if err := m.Stop(); err != nil {
+			return err
+		}
+	}
+	if delay > 0 {
+		sleep(delay)
+	}
+	return m.Start()
+}
+
+// probe addresses the device at addr for writing and sends a stop
+// condition right after the address byte. It reports whether the
+// device ACKed its address. A NACK is not treated as an error.
+func probe(m I2CMaster, addr Addr) (bool, error) {
+	if err := m.Start(); err != nil {
+		return false, err
+	}
+
+	err := m.WriteByte(uint8(addr.GetBaseAddr() << 1))
+	if err != nil && err != NACKReceived {
+		m.Stop()
+		return false, err
+	}
+
+	if serr := m.Stop(); serr != nil {
+		return false, serr
+	}
+
+	return err == nil, nil
+}
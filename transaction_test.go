@@ -5,8 +5,10 @@
 package i2cm
 
 import (
+	"errors"
 	"fmt"
 	"testing"
+	"time"
 )
 
 type alwaysNACK struct{}
@@ -72,33 +74,13 @@ func (i i2cItem) String() string {
 	return "unknown i2cItem typ"
 }
 
-type i2cRecorder struct {
-	m   I2CMaster
-	log []i2cItem
-}
-
-func (r *i2cRecorder) Start() error {
-	err := r.m.Start()
-	r.log = append(r.log, i2cItem{t_START, 0, false, err})
-	return err
-}
-
-func (r *i2cRecorder) Stop() error {
-	err := r.m.Start()
-	r.log = append(r.log, i2cItem{t_STOP, 0, false, err})
-	return err
-}
-
-func (r *i2cRecorder) ReadByte(ack bool) (byte, error) {
-	b, err := r.m.ReadByte(ack)
-	r.log = append(r.log, i2cItem{t_READ, b, ack, err})
-	return b, err
-}
-
-func (r *i2cRecorder) WriteByte(b byte) error {
-	err := r.m.WriteByte(b)
-	r.log = append(r.log, i2cItem{t_WRITE, b, false, err})
-	return err
+// recorded returns the operations recorded by r as i2cItems.
+func recorded(r *RecordingMaster) []i2cItem {
+	var log []i2cItem
+	for _, e := range r.Log() {
+		log = append(log, i2cItem{int(e.Op), e.Byte, e.ACK, e.Err})
+	}
+	return log
 }
 
 const (
@@ -289,7 +271,7 @@ func TestTransactionLog(t *testing.T) {
 caseloop:
 	for j, tc := range cases {
 		md256 := newmemdev256(Addr7(0xa0 >> 1))
-		m := &i2cRecorder{md256, nil}
+		m := NewRecordingMaster(md256)
 
 		so := int(tc.regaddr) + len(tc.wb)
 		eo := so + len(tc.erb)
@@ -327,9 +309,10 @@ caseloop:
 			continue
 		}
 
-		if len(m.log) > len(tc.explog) {
+		log := recorded(m)
+		if len(log) > len(tc.explog) {
 			t.Errorf("real log for test case %d is longer than expected log\n", j)
-			t.Errorf("real log: %#v\n", m.log)
+			t.Errorf("real log: %#v\n", log)
 			t.Errorf("exp log: %#v\n", tc.explog)
 			continue caseloop
 		}
@@ -349,7 +332,7 @@ caseloop:
 		}
 
 		// check i2c log
-		for i, e := range m.log {
+		for i, e := range log {
 			if e != tc.explog[i] {
 				t.Errorf("test case %d: i2c log differs at item %d. expected %v, got %v", j, i, tc.explog[i], e)
 				continue caseloop
@@ -357,3 +340,403 @@ caseloop:
 		}
 	}
 }
+
+func TestTransactEx8x8(t *testing.T) {
+	res := NewTransactEx8x8(&alwaysNACK{}).TransactEx8x8(Addr7(0x50), 0, []byte{1}, nil)
+	if res.Err != NoSuchDevice || res.Completed || res.Phase != PhaseAddress {
+		t.Fatalf("expected NoSuchDevice in phase %v, got %#v", PhaseAddress, res)
+	}
+
+	md256 := newmemdev256(Addr7(0x50))
+	res = NewTransactEx8x8(md256).TransactEx8x8(Addr7(0x50), 0x10, []byte{1, 2}, make([]byte, 3))
+	if res.Err != nil || !res.Completed || res.Phase != PhaseStop {
+		t.Fatalf("expected completed transaction, got %#v", res)
+	}
+	if res.BytesWritten != 2 || res.BytesRead != 3 {
+		t.Fatalf("expected 2 bytes written and 3 read, got %d and %d", res.BytesWritten, res.BytesRead)
+	}
+	// address, register address, 2 data bytes, read address, 3 data bytes
+	if res.ByteOps != 8 {
+		t.Fatalf("expected 8 byte operations, got %d", res.ByteOps)
+	}
+}
+
+func TestDummyFirstRead(t *testing.T) {
+	md256 := newmemdev256(Addr7(0x50))
+	copy(md256.mem[0x30:], []byte{0xaa, 0x80, 0x81})
+	m := NewRecordingMaster(md256)
+
+	tr := NewTransact8x8WithOptions(m, Transact8x8Options{DummyFirstRead: true})
+	rb := make([]byte, 2)
+	nw, nr, err := tr.Transact8x8(Addr7(0x50), 0x30, nil, rb)
+	if err != nil || nw != 0 || nr != 2 {
+		t.Fatalf("expected (0, 2, nil), got (%d, %d, %v)", nw, nr, err)
+	}
+	if string(rb) != "\x80\x81" {
+		t.Fatalf("expected to read 80 81, read % x", rb)
+	}
+
+	explog := []i2cItem{{t_START, 0, false, nil},
+		{t_WRITE, 0xa0, false, nil},
+		{t_WRITE, 0x30, false, nil},
+		{t_START, 0x00, false, nil},
+		{t_WRITE, 0xa1, false, nil},
+		{t_READ, 0xaa, true, nil}, // dummy byte
+		{t_READ, 0x80, true, nil},
+		{t_READ, 0x81, false, nil},
+		{t_STOP, 0x00, false, nil},
+	}
+	checkLog(t, recorded(m), explog)
+}
+
+func checkLog(t *testing.T, log, explog []i2cItem) {
+	if len(log) != len(explog) {
+		t.Fatalf("expected log of %d items, got %d: %v", len(explog), len(log), log)
+	}
+	for i, e := range log {
+		if e != explog[i] {
+			t.Fatalf("i2c log differs at item %d. expected %v, got %v", i, explog[i], e)
+		}
+	}
+}
+
+type repeatedStartRecorder struct {
+	*RecordingMaster
+	supported bool
+}
+
+func (r repeatedStartRecorder) SupportsRepeatedStart() bool {
+	return r.supported
+}
+
+func TestNoRepeatedStart(t *testing.T) {
+	for _, supported := range []bool{true, false} {
+		md256 := newmemdev256(Addr7(0x50))
+		copy(md256.mem[0x30:], []byte{0x80, 0x81})
+		rec := NewRecordingMaster(md256)
+		m := repeatedStartRecorder{rec, supported}
+
+		rb := make([]byte, 2)
+		if _, _, err := NewTransact8x8(m).Transact8x8(Addr7(0x50), 0x30, nil, rb); err != nil {
+			t.Fatalf("supported %v: transaction failed: %v", supported, err)
+		}
+		if string(rb) != "\x80\x81" {
+			t.Fatalf("supported %v: expected to read 80 81, read % x", supported, rb)
+		}
+
+		explog := []i2cItem{{t_START, 0, false, nil},
+			{t_WRITE, 0xa0, false, nil},
+			{t_WRITE, 0x30, false, nil},
+		}
+		if !supported {
+			explog = append(explog, i2cItem{t_STOP, 0x00, false, nil})
+		}
+		explog = append(explog, []i2cItem{{t_START, 0x00, false, nil},
+			{t_WRITE, 0xa1, false, nil},
+			{t_READ, 0x80, true, nil},
+			{t_READ, 0x81, false, nil},
+			{t_STOP, 0x00, false, nil},
+		}...)
+		checkLog(t, recorded(rec), explog)
+	}
+}
+
+func TestReadBlockAckLast(t *testing.T) {
+	m := NewRecordingMaster(&scriptedMaster{rd: []byte{1, 2, 3}})
+
+	m.Start()
+	m.WriteByte(0xa1)
+	r1 := make([]byte, 2)
+	if n, err := ReadBlock(m, r1, true); n != 2 || err != nil {
+		t.Fatalf("expected to read 2 bytes, got %d, %v", n, err)
+	}
+	r2 := make([]byte, 1)
+	if n, err := ReadBlock(m, r2, false); n != 1 || err != nil {
+		t.Fatalf("expected to read 1 byte, got %d, %v", n, err)
+	}
+	m.Stop()
+
+	checkLog(t, recorded(m), []i2cItem{{t_START, 0, false, nil},
+		{t_WRITE, 0xa1, false, nil},
+		{t_READ, 0x01, true, nil},
+		{t_READ, 0x02, true, nil}, // last byte of the first block is ACKed
+		{t_READ, 0x03, false, nil},
+		{t_STOP, 0x00, false, nil},
+	})
+}
+
+// subAddr is a device address followed by a sub-address byte.
+type subAddr struct {
+	Addr7
+	sub byte
+}
+
+func (a subAddr) WriteFrame() []byte { return []byte{uint8(a.Addr7) << 1, a.sub} }
+func (a subAddr) ReadFrame() []byte  { return []byte{uint8(a.Addr7)<<1 | 0x01, a.sub} }
+
+// TestFramingGolden runs operations with various framing options on a
+// recorder and compares the bus traffic to golden logs, so that changes
+// to the shared transaction code which alter the framing of any option
+// are caught.
+func TestFramingGolden(t *testing.T) {
+	S := i2cItem{t_START, 0, false, nil}
+	P := i2cItem{t_STOP, 0, false, nil}
+	W := func(b byte) i2cItem { return i2cItem{t_WRITE, b, false, nil} }
+	WN := func(b byte) i2cItem { return i2cItem{t_WRITE, b, false, NACKReceived} }
+	R := func(b byte, ack bool) i2cItem { return i2cItem{t_READ, b, ack, nil} }
+
+	scripted := func(rd ...byte) func() I2CMaster {
+		return func() I2CMaster { return &scriptedMaster{rd: rd} }
+	}
+
+	cases := []struct {
+		name string
+		m    func() I2CMaster
+		op   func(m I2CMaster) error
+		exp  []i2cItem
+	}{
+		{"8x8 write", scripted(), func(m I2CMaster) error {
+			_, _, err := NewTransactor(m).Transact8x8(Addr7(0x50), 0x12, []byte{0xab}, nil)
+			return err
+		}, []i2cItem{S, W(0xa0), W(0x12), W(0xab), P}},
+
+		{"8x8 read", scripted(1, 2), func(m I2CMaster) error {
+			_, _, err := NewTransactor(m).Transact8x8(Addr7(0x50), 0x12, nil, make([]byte, 2))
+			return err
+		}, []i2cItem{S, W(0xa0), W(0x12), S, W(0xa1), R(1, true), R(2, false), P}},
+
+		{"16x8 write then read", scripted(1), func(m I2CMaster) error {
+			_, _, err := NewTransactor(m).Transact16x8(Addr7(0x50), 0x1234, []byte{0xab}, make([]byte, 1))
+			return err
+		}, []i2cItem{S, W(0xa0), W(0x12), W(0x34), W(0xab), S, W(0xa1), R(1, false), P}},
+
+		{"10 bit write", scripted(), func(m I2CMaster) error {
+			_, _, err := NewTransactor(m).Transact8x8(Addr10(0x3a5), 0x12, []byte{0xab}, nil)
+			return err
+		}, []i2cItem{S, W(0xf6), W(0xa5), W(0x12), W(0xab), P}},
+
+		{"10 bit read", scripted(1, 2), func(m I2CMaster) error {
+			_, _, err := NewTransactor(m).Transact8x8(Addr10(0x3a5), 0x12, nil, make([]byte, 2))
+			return err
+		}, []i2cItem{S, W(0xf6), W(0xa5), W(0x12), S, W(0xf7), R(1, true), R(2, false), P}},
+
+		{"10 bit low address byte NACKed", func() I2CMaster { return &nackAfter{n: 1} }, func(m I2CMaster) error {
+			nw, nr, err := NewTransactor(m).Transact8x8(Addr10(0x0a5), 0x12, []byte{1}, make([]byte, 1))
+			if nw != 0 || nr != 0 || err != NoSuchDevice {
+				return fmt.Errorf("expected (0, 0, NoSuchDevice), got (%d, %d, %v)", nw, nr, err)
+			}
+			return nil
+		}, []i2cItem{S, W(0xf0), WN(0xa5), P}},
+
+		{"10 bit read without repeated start", scripted(1), func(m I2CMaster) error {
+			if _, _, err := NewTransactor(m, WithNoRepeatedStart()).Transact8x8(Addr10(0x3a5), 0x12, nil, make([]byte, 1)); err == nil {
+				return errors.New("10 bit read without repeated start succeeded")
+			}
+			return nil
+		}, nil},
+
+		{"AddrFramer", scripted(1), func(m I2CMaster) error {
+			_, _, err := NewTransactor(m).Transact8x8(subAddr{0x50, 0x07}, 0x12, nil, make([]byte, 1))
+			return err
+		}, []i2cItem{S, W(0xa0), W(0x07), W(0x12), S, W(0xa1), W(0x07), R(1, false), P}},
+
+		{"no repeated start", scripted(1), func(m I2CMaster) error {
+			_, _, err := NewTransactor(m, WithNoRepeatedStart()).Transact8x8(Addr7(0x50), 0x12, nil, make([]byte, 1))
+			return err
+		}, []i2cItem{S, W(0xa0), W(0x12), P, S, W(0xa1), R(1, false), P}},
+
+		{"dummy first read", scripted(0xff, 1), func(m I2CMaster) error {
+			_, _, err := NewTransactor(m, WithDummyFirstRead()).Transact8x8(Addr7(0x50), 0x12, nil, make([]byte, 1))
+			return err
+		}, []i2cItem{S, W(0xa0), W(0x12), S, W(0xa1), R(0xff, true), R(1, false), P}},
+
+		{"stop on NACK", func() I2CMaster { return &nackAfter{n: 3} }, func(m I2CMaster) error {
+			_, _, err := NewTransactor(m, WithStopOnNACK()).Transact8x8(Addr7(0x50), 0x12, []byte{1, 2}, nil)
+			return err
+		}, []i2cItem{S, W(0xa0), W(0x12), W(1), WN(2), P}},
+
+		{"max read length", scripted(1, 2, 3), func(m I2CMaster) error {
+			_, _, err := NewTransactor(m, WithMaxReadLen(2)).Transact8x8(Addr7(0x50), 0x12, nil, make([]byte, 3))
+			return err
+		}, []i2cItem{S, W(0xa0), W(0x12), S, W(0xa1), R(1, true), R(2, false), P,
+			S, W(0xa0), W(0x14), S, W(0xa1), R(3, false), P}},
+
+		{"2 byte read pointer", scripted(1), func(m I2CMaster) error {
+			p, err := NewPointerTransactor(m, 1, 2)
+			if err != nil {
+				return err
+			}
+			_, err = p.Read(Addr7(0x50), 0x1234, make([]byte, 1))
+			return err
+		}, []i2cItem{S, W(0xa0), W(0x12), W(0x34), S, W(0xa1), R(1, false), P}},
+
+		{"byte at a time", scripted(1, 2), func(m I2CMaster) error {
+			_, _, err := NewTransactor(m, WithByteAtATime()).Transact8x8(Addr7(0x50), 0x12, []byte{0xab}, make([]byte, 2))
+			return err
+		}, []i2cItem{S, W(0xa0), W(0x12), W(0xab), P,
+			S, W(0xa0), W(0x13), S, W(0xa1), R(1, false), P,
+			S, W(0xa0), W(0x14), S, W(0xa1), R(2, false), P}},
+
+		{"count prefixed read", scripted(1, 0xaa), func(m I2CMaster) error {
+			_, err := ReadCountPrefixed(m, Addr7(0x50), 0x12, 4)
+			return err
+		}, []i2cItem{S, W(0xa0), W(0x12), S, W(0xa1), R(1, true), R(0xaa, false), P}},
+
+		{"10 bit count prefixed read", scripted(1, 0xaa), func(m I2CMaster) error {
+			_, err := ReadCountPrefixed(m, Addr10(0x3a5), 0x12, 4)
+			return err
+		}, []i2cItem{S, W(0xf6), W(0xa5), W(0x12), S, W(0xf7), R(1, true), R(0xaa, false), P}},
+
+		{"count prefixed read, read address NACKed", func() I2CMaster { return &nackAfter{n: 2} }, func(m I2CMaster) error {
+			if _, err := ReadCountPrefixed(m, Addr7(0x50), 0x12, 4); err != NoSuchDevice {
+				return fmt.Errorf("expected NoSuchDevice, got %v", err)
+			}
+			return nil
+		}, []i2cItem{S, W(0xa0), W(0x12), S, WN(0xa1), P}},
+	}
+
+	for _, c := range cases {
+		m := NewRecordingMaster(c.m())
+		if err := c.op(m); err != nil {
+			t.Errorf("%s: unexpected error %v", c.name, err)
+			continue
+		}
+		if log := recorded(m); fmt.Sprint(log) != fmt.Sprint(c.exp) {
+			t.Errorf("%s: framing differs\n got: %v\nwant: %v", c.name, log, c.exp)
+		}
+	}
+}
+
+func TestReadCountPrefixed(t *testing.T) {
+	m := NewRecordingMaster(&scriptedMaster{rd: []byte{2, 0xaa, 0xbb}})
+	data, err := ReadCountPrefixed(m, Addr7(0x50), 0x10, 4)
+	if string(data) != "\xaa\xbb" || err != nil {
+		t.Fatalf("expected aa bb, got % x, %v", data, err)
+	}
+	checkLog(t, recorded(m), []i2cItem{{t_START, 0, false, nil},
+		{t_WRITE, 0xa0, false, nil},
+		{t_WRITE, 0x10, false, nil},
+		{t_START, 0, false, nil},
+		{t_WRITE, 0xa1, false, nil},
+		{t_READ, 0x02, true, nil}, // count
+		{t_READ, 0xaa, true, nil},
+		{t_READ, 0xbb, false, nil},
+		{t_STOP, 0x00, false, nil},
+	})
+
+	// an empty block ends with a NACKed dummy byte
+	m = NewRecordingMaster(&scriptedMaster{rd: []byte{0, 0xff}})
+	if data, err := ReadCountPrefixed(m, Addr7(0x50), 0x10, 4); len(data) != 0 || err != nil {
+		t.Fatalf("expected no data, got % x, %v", data, err)
+	}
+	checkLog(t, recorded(m)[5:], []i2cItem{{t_READ, 0x00, true, nil},
+		{t_READ, 0xff, false, nil},
+		{t_STOP, 0x00, false, nil},
+	})
+
+	// the memdev checks that the read is NACKed before the stop
+	dev := newmemdev256(Addr7(0x50))
+	copy(dev.mem[0x10:], []byte{5, 1, 2, 3, 4, 5})
+	if _, err := ReadCountPrefixed(dev, Addr7(0x50), 0x10, 4); err == nil {
+		t.Fatalf("count exceeding the maximum accepted")
+	}
+	if data, err := ReadCountPrefixed(dev, Addr7(0x50), 0x10, 5); string(data) != "\x01\x02\x03\x04\x05" || err != nil {
+		t.Fatalf("expected 01 02 03 04 05, got % x, %v", data, err)
+	}
+	if _, err := ReadCountPrefixed(&alwaysNACK{}, Addr7(0x50), 0x10, 5); err != NoSuchDevice {
+		t.Fatalf("expected NoSuchDevice, got %v", err)
+	}
+}
+
+func TestTransactInPlace8x8(t *testing.T) {
+	md256 := newmemdev256(Addr7(0x50))
+	copy(md256.mem[0x12:], []byte{0x80, 0x81})
+
+	buf := []byte{0x01, 0x02}
+	if err := TransactInPlace8x8(NewTransact8x8(md256), Addr7(0x50), 0x10, buf); err != nil {
+		t.Fatalf("transaction failed: %v", err)
+	}
+	if string(md256.mem[0x10:0x12]) != "\x01\x02" {
+		t.Fatalf("expected 01 02 to be written, memory holds % x", md256.mem[0x10:0x12])
+	}
+	if string(buf) != "\x80\x81" {
+		t.Fatalf("expected to read 80 81, read % x", buf)
+	}
+}
+
+// failingStart fails the failAt-th start condition, counting from 1.
+type failingStart struct {
+	scriptedMaster
+	failAt int
+	n      int
+}
+
+var errStartFailed = errors.New("start failed")
+
+func (f *failingStart) Start() error {
+	f.n++
+	if f.n == f.failAt {
+		return errStartFailed
+	}
+	return nil
+}
+
+func TestReadPhaseStartFailed(t *testing.T) {
+	m := &failingStart{failAt: 2}
+	res := NewTransactEx8x8(m).TransactEx8x8(Addr7(0x50), 0x10, []byte{1, 2}, make([]byte, 1))
+	if !errors.Is(res.Err, ErrReadPhaseStartFailed) || !errors.Is(res.Err, errStartFailed) {
+		t.Fatalf("expected an error wrapping ErrReadPhaseStartFailed and the master's error, got %v", res.Err)
+	}
+	if res.BytesWritten != 2 || res.BytesRead != 0 || res.Phase != PhaseRestart {
+		t.Fatalf("expected 2 bytes written in phase %v, got %#v", PhaseRestart, res)
+	}
+
+	// a failing first start is not affected
+	m = &failingStart{failAt: 1}
+	if _, _, err := NewTransact8x8(m).Transact8x8(Addr7(0x50), 0x10, nil, make([]byte, 1)); err != errStartFailed {
+		t.Fatalf("expected the master's error, got %v", err)
+	}
+}
+
+func TestEstimateDuration8x8(t *testing.T) {
+	cases := []struct {
+		w, r []byte
+		exp  time.Duration
+	}{
+		// start, 2 bytes, stop
+		{nil, nil, 20 * 10 * time.Microsecond},
+		// start, 4 bytes, stop
+		{[]byte{1, 2}, nil, 38 * 10 * time.Microsecond},
+		// start, 2 bytes, repeated start, 4 bytes, stop
+		{nil, []byte{1, 2, 3}, 57 * 10 * time.Microsecond},
+		// start, 3 bytes, repeated start, 2 bytes, stop
+		{[]byte{1}, []byte{1}, 48 * 10 * time.Microsecond},
+	}
+
+	for i, c := range cases {
+		if d := EstimateDuration8x8(100e3, c.w, c.r); d != c.exp {
+			t.Errorf("case %d: expected %v, got %v", i, c.exp, d)
+		}
+	}
+}
+
+func TestIsEmulated(t *testing.T) {
+	pvt := newPVT24(Conf_24C02, t)
+	if IsEmulated(NewTransact16x8(pvt)) || IsEmulated(NewTransactor(pvt)) {
+		t.Errorf("native Transactor16x8 reported as emulated")
+	}
+
+	md256 := newmemdev256(Addr7(0x50))
+	if !IsEmulated(NewTransact16x8(md256)) || !IsEmulated(NewTransactor(md256)) {
+		t.Errorf("emulated Transactor16x8 reported as native")
+	}
+
+	// decorators are looked through
+	opts := []TransactorOption{WithRetry(2), WithMaxConsecutiveNACKs(3), WithMaxReadLen(8)}
+	if !IsEmulated(RateLimit(Arbitrate(NewTransactor(md256, opts...), Arbiter{}), time.Millisecond)) {
+		t.Errorf("decorated emulated Transactor16x8 reported as native")
+	}
+	if IsEmulated(RateLimit(Arbitrate(NewTransactor(pvt, opts...), Arbiter{}), time.Millisecond)) {
+		t.Errorf("decorated native Transactor16x8 reported as emulated")
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"errors"
+)
+
+var errNoTransactFunc = errors.New("I2C transaction: no function for this transaction type")
+
+// Transact8x8Func is a function carrying out 8x8 transactions, which
+// implements Transactor8x8 by calling itself.
+type Transact8x8Func func(addr Addr, regaddr uint8, w, r []byte) (int, int, error)
+
+func (f Transact8x8Func) Transact8x8(addr Addr, regaddr uint8, w, r []byte) (int, int, error) {
+	return f(addr, regaddr, w, r)
+}
+
+// Transact16x8Func is a function carrying out 16x8 transactions, which
+// implements Transactor16x8 by calling itself.
+type Transact16x8Func func(addr Addr, regaddr uint16, w, r []byte) (int, int, error)
+
+func (f Transact16x8Func) Transact16x8(addr Addr, regaddr uint16, w, r []byte) (int, int, error) {
+	return f(addr, regaddr, w, r)
+}
+
+// TransactorFunc returns a Transactor8x8 carrying out transactions by
+// calling fn, e.g. to mock devices in tests.
+func TransactorFunc(fn func(addr Addr, regaddr uint8, w, r []byte) (int, int, error)) Transactor8x8 {
+	return Transact8x8Func(fn)
+}
+
+// TransactorFunc16x8 returns a Transactor16x8 carrying out transactions
+// by calling fn.
+func TransactorFunc16x8(fn func(addr Addr, regaddr uint16, w, r []byte) (int, int, error)) Transactor16x8 {
+	return Transact16x8Func(fn)
+}
+
+// TransactorFuncs returns a Transactor carrying out 8x8 transactions by
+// calling fn8x8 and 16x8 transactions by calling fn16x8. Transactions
+// for which the function is nil fail without transferring data.
+func TransactorFuncs(fn8x8 func(addr Addr, regaddr uint8, w, r []byte) (int, int, error), fn16x8 func(addr Addr, regaddr uint16, w, r []byte) (int, int, error)) Transactor {
+	if fn8x8 == nil {
+		fn8x8 = func(Addr, uint8, []byte, []byte) (int, int, error) { return 0, 0, errNoTransactFunc }
+	}
+	if fn16x8 == nil {
+		fn16x8 = func(Addr, uint16, []byte, []byte) (int, int, error) { return 0, 0, errNoTransactFunc }
+	}
+	return &transactor{Transact8x8Func(fn8x8), Transact16x8Func(fn16x8)}
+}
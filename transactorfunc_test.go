@@ -0,0 +1,42 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"testing"
+)
+
+func TestTransactorFunc(t *testing.T) {
+	var got8 uint8
+	fn8 := func(addr Addr, regaddr uint8, w, r []byte) (int, int, error) {
+		got8 = regaddr
+		for i := range r {
+			r[i] = regaddr + uint8(i)
+		}
+		return len(w), len(r), nil
+	}
+	var got16 uint16
+	fn16 := func(addr Addr, regaddr uint16, w, r []byte) (int, int, error) {
+		got16 = regaddr
+		return len(w), len(r), nil
+	}
+
+	r := make([]byte, 2)
+	if _, nr, err := TransactorFunc(fn8).Transact8x8(Addr7(0x50), 0x10, nil, r); nr != 2 || err != nil || string(r) != "\x10\x11" {
+		t.Fatalf("expected to read 10 11, got %d, %v, % x", nr, err, r)
+	}
+	if nw, _, err := TransactorFunc16x8(fn16).Transact16x8(Addr7(0x50), 0x1234, []byte{1}, nil); nw != 1 || err != nil || got16 != 0x1234 {
+		t.Fatalf("expected the 16x8 function to be called, got %d, %v, %#x", nw, err, got16)
+	}
+
+	tr := TransactorFuncs(fn8, nil)
+	if _, _, err := tr.Transact8x8(Addr7(0x50), 0x20, nil, nil); err != nil || got8 != 0x20 {
+		t.Fatalf("expected the 8x8 function to be called, got %v, %#x", err, got8)
+	}
+	if nw, nr, err := tr.Transact16x8(Addr7(0x50), 0, []byte{1}, r); nw != 0 || nr != 0 || err == nil {
+		t.Fatalf("expected the missing 16x8 function to fail, got %d, %d, %v", nw, nr, err)
+	}
+
+}
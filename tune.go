@@ -0,0 +1,146 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	// tuneMinWriteDelay is the shortest write delay TuneEEPROM24 tries.
+	tuneMinWriteDelay = 250 * time.Microsecond
+
+	// tuneStartWriteDelay is the write delay TuneEEPROM24 starts with
+	// if none is configured.
+	tuneStartWriteDelay = 10 * time.Millisecond
+)
+
+// tuneReadChunks are the read transaction sizes TuneEEPROM24 times.
+var tuneReadChunks = []int{16, 32, 64, 128, 256}
+
+// TunedParams are the parameters recommended by TuneEEPROM24.
+type TunedParams struct {
+	// WriteDelay is twice the shortest write delay with which the
+	// device passed the write test, capped at the configured delay.
+	WriteDelay time.Duration
+
+	// ReadChunk is the read transaction size with the highest
+	// throughput, e.g. for the chunks of a copy.
+	ReadChunk int
+}
+
+// TuneEEPROM24 measures the write cycle time and read throughput of the
+// device behind e, which needs to be an EEPROM24 returned by
+// NewEEPROM24. It writes test patterns to the last page of the array,
+// halving the write delay from the configured one, or 10 ms if none is
+// configured, until the device is still busy or the data does not read
+// back correctly after the delay. The read throughput is timed for
+// transactions of 16 to 256 bytes. The original contents of the last
+// page are restored with the configured write delay, and the file
+// pointer is not changed. The returned parameters are recommendations
+// for the caller to apply; e's configuration is not changed.
+//
+// TuneEEPROM24 should only be run against known good devices, as it
+// provokes failing accesses and uses up write cycles of the last page.
+func TuneEEPROM24(e EEPROM24) (TunedParams, error) {
+	ee, ok := e.(*ee24)
+	if !ok {
+		return TunedParams{}, errors.New("TuneEEPROM24: EEPROM24 needs to be created by NewEEPROM24")
+	}
+	ee.mu.Lock()
+	defer ee.mu.Unlock()
+
+	if ee.conf.ReadOnly {
+		return TunedParams{}, ErrReadOnly
+	}
+
+	start := ee.conf.WriteDelay
+	if start == 0 {
+		start = tuneStartWriteDelay
+	}
+
+	off := ee.conf.Size - ee.conf.PageSize
+	orig := make([]byte, ee.conf.PageSize)
+	if _, err := ee.readAt(orig, off); err != nil {
+		return TunedParams{}, err
+	}
+
+	params, err := ee.tune(off, start)
+
+	// restore the page, even if tuning failed
+	if _, rerr := ee.writeAtWith(context.Background(), orig, off, WriteModeDelay(start)); err == nil {
+		err = rerr
+	}
+	return params, err
+}
+
+// tune carries out the measurements of TuneEEPROM24 on the page at off.
+func (e *ee24) tune(off uint, start time.Duration) (TunedParams, error) {
+	var params TunedParams
+
+	pattern := make([]byte, e.conf.PageSize)
+	check := make([]byte, e.conf.PageSize)
+	good := time.Duration(0)
+	for i, d := 0, start; d >= tuneMinWriteDelay; i, d = i+1, d/2 {
+		for j := range pattern {
+			pattern[j] = uint8(i*0x3b+j) ^ 0xa5
+		}
+
+		if _, err := e.writeAtWith(context.Background(), pattern, off, WriteModeDelay(d)); err != nil {
+			return params, err
+		}
+		_, err := e.readAt(check, off)
+		if err == nil && string(check) == string(pattern) {
+			good = d
+			continue
+		}
+		if err != nil && err != NoSuchDevice && err != NACKReceived {
+			return params, err
+		}
+
+		// the device is still busy or lost the data, give it time to
+		// finish the write cycle
+		sleep(start)
+		break
+	}
+	if good == 0 {
+		return params, errors.New("TuneEEPROM24: write test failed with the configured write delay")
+	}
+
+	params.WriteDelay = 2 * good
+	if params.WriteDelay > start {
+		params.WriteDelay = start
+	}
+
+	// time reads of the whole region for every chunk size
+	n := tuneReadChunks[len(tuneReadChunks)-1]
+	if uint(n) > e.conf.Size {
+		n = int(e.conf.Size)
+	}
+	buf := make([]byte, n)
+	var best time.Duration
+	for _, c := range tuneReadChunks {
+		if c > n {
+			break
+		}
+		t0 := time.Now()
+		for p := 0; p < n; p += c {
+			if _, err := e.readAt(buf[p:p+c], uint(p)); err != nil {
+				return params, err
+			}
+		}
+		if d := time.Since(t0); params.ReadChunk == 0 || d < best {
+			params.ReadChunk, best = c, d
+		}
+	}
+	if params.ReadChunk == 0 {
+		// the array is smaller than the smallest chunk
+		params.ReadChunk = n
+	}
+
+	return params, nil
+}
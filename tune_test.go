@@ -0,0 +1,89 @@
+// Copyright 2012 Michael Meier. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package i2cm
+
+import (
+	"testing"
+	"time"
+)
+
+// busyEEPROM24 is a memEEPROM24 which does not respond for cycle after
+// each write. Time passes only by the faked sleeps recorded in delays.
+type busyEEPROM24 struct {
+	*memEEPROM24
+	cycle  time.Duration
+	delays *[]time.Duration
+	mark   int // number of delays at the last write, -1 before the first
+}
+
+func (b *busyEEPROM24) transact(memaddr uint, w, r []byte) (int, int, error) {
+	if b.mark >= 0 {
+		var elapsed time.Duration
+		for _, d := range (*b.delays)[b.mark:] {
+			elapsed += d
+		}
+		if elapsed < b.cycle {
+			return 0, 0, NoSuchDevice
+		}
+	}
+	if len(w) > 0 {
+		b.mark = len(*b.delays)
+	}
+	return b.memEEPROM24.transact(memaddr, w, r)
+}
+
+func (b *busyEEPROM24) Transact8x8(addr Addr, regaddr uint8, w, r []byte) (int, int, error) {
+	return b.transact((uint(addr.GetBaseAddr())&0x07)<<8|uint(regaddr), w, r)
+}
+
+func (b *busyEEPROM24) Transact16x8(addr Addr, regaddr uint16, w, r []byte) (int, int, error) {
+	return b.transact((uint(addr.GetBaseAddr())&0x07)<<16|uint(regaddr), w, r)
+}
+
+func TestTuneEEPROM24(t *testing.T) {
+	delays := fakeSleep(t)
+
+	conf := EEPROM24Config{Size: 2048, PageSize: 16, WriteDelay: 8 * time.Millisecond}
+	dev := &busyEEPROM24{newMemEEPROM24(conf), 1500 * time.Microsecond, delays, -1}
+	fillPattern(dev.mem, 0x33)
+	orig := append([]byte(nil), dev.mem...)
+
+	ee := newTestEEPROM24(t, conf, dev)
+	ee.Seek(100, 0)
+
+	params, err := TuneEEPROM24(ee)
+	if err != nil {
+		t.Fatalf("TuneEEPROM24 failed: %v", err)
+	}
+
+	// 8, 4 and 2 ms pass, 1 ms fails; twice 2 ms is recommended
+	if params.WriteDelay != 4*time.Millisecond {
+		t.Errorf("expected a write delay of 4ms, got %v", params.WriteDelay)
+	}
+	found := false
+	for _, c := range tuneReadChunks {
+		found = found || c == params.ReadChunk
+	}
+	if !found {
+		t.Errorf("unexpected read chunk size %d", params.ReadChunk)
+	}
+
+	if string(dev.mem) != string(orig) {
+		t.Errorf("TuneEEPROM24 did not restore the memory")
+	}
+	if ee.Tell() != 100 {
+		t.Errorf("TuneEEPROM24 moved the file pointer to %d", ee.Tell())
+	}
+
+	// a device slower than the configured delay fails the test
+	dev.cycle = 10 * time.Millisecond
+	if _, err := TuneEEPROM24(ee); err == nil {
+		t.Errorf("TuneEEPROM24 accepted a device slower than the configured delay")
+	}
+
+	if _, err := TuneEEPROM24(&stripedEEPROM24{}); err == nil {
+		t.Errorf("TuneEEPROM24 accepted a foreign EEPROM24")
+	}
+}